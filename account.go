@@ -0,0 +1,133 @@
+package bitmex
+
+import (
+	"math"
+	"time"
+)
+
+// XBt - BitMEX currency code of amounts in satoshis
+const XBt = "XBt"
+
+// Satoshi - amount in satoshis (XBt)
+type Satoshi int64
+
+// XBT - amount in bitcoins
+func (s Satoshi) XBT() float64 {
+	return float64(s) / 1e8
+}
+
+// SatoshiFromXBT - converts bitcoins to satoshis
+func SatoshiFromXBT(xbt float64) Satoshi {
+	return Satoshi(math.Floor(xbt*1e8 + 0.5))
+}
+
+// Wallet - wallet balance and transfers totals
+type Wallet struct {
+	Account          int64     `json:"account"`
+	Currency         string    `json:"currency"`
+	PrevDeposited    Satoshi   `json:"prevDeposited"`
+	PrevWithdrawn    Satoshi   `json:"prevWithdrawn"`
+	PrevTransferIn   Satoshi   `json:"prevTransferIn"`
+	PrevTransferOut  Satoshi   `json:"prevTransferOut"`
+	PrevAmount       Satoshi   `json:"prevAmount"`
+	PrevTimestamp    time.Time `json:"prevTimestamp"`
+	DeltaDeposited   Satoshi   `json:"deltaDeposited"`
+	DeltaWithdrawn   Satoshi   `json:"deltaWithdrawn"`
+	DeltaTransferIn  Satoshi   `json:"deltaTransferIn"`
+	DeltaTransferOut Satoshi   `json:"deltaTransferOut"`
+	DeltaAmount      Satoshi   `json:"deltaAmount"`
+	Deposited        Satoshi   `json:"deposited"`
+	Withdrawn        Satoshi   `json:"withdrawn"`
+	TransferIn       Satoshi   `json:"transferIn"`
+	TransferOut      Satoshi   `json:"transferOut"`
+	Amount           Satoshi   `json:"amount"`
+	PendingCredit    Satoshi   `json:"pendingCredit"`
+	PendingDebit     Satoshi   `json:"pendingDebit"`
+	ConfirmedDebit   Satoshi   `json:"confirmedDebit"`
+	Addr             string    `json:"addr"`
+	Script           string    `json:"script"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// Margin - account margin state
+type Margin struct {
+	Account            int64     `json:"account"`
+	Currency           string    `json:"currency"`
+	RiskLimit          Satoshi   `json:"riskLimit"`
+	State              string    `json:"state"`
+	Action             string    `json:"action"`
+	Amount             Satoshi   `json:"amount"`
+	PendingCredit      Satoshi   `json:"pendingCredit"`
+	PendingDebit       Satoshi   `json:"pendingDebit"`
+	ConfirmedDebit     Satoshi   `json:"confirmedDebit"`
+	PrevRealisedPnl    Satoshi   `json:"prevRealisedPnl"`
+	PrevUnrealisedPnl  Satoshi   `json:"prevUnrealisedPnl"`
+	GrossComm          Satoshi   `json:"grossComm"`
+	GrossOpenCost      Satoshi   `json:"grossOpenCost"`
+	GrossOpenPremium   Satoshi   `json:"grossOpenPremium"`
+	GrossExecCost      Satoshi   `json:"grossExecCost"`
+	GrossMarkValue     Satoshi   `json:"grossMarkValue"`
+	RiskValue          Satoshi   `json:"riskValue"`
+	TaxableMargin      Satoshi   `json:"taxableMargin"`
+	InitMargin         Satoshi   `json:"initMargin"`
+	MaintMargin        Satoshi   `json:"maintMargin"`
+	SessionMargin      Satoshi   `json:"sessionMargin"`
+	TargetExcessMargin Satoshi   `json:"targetExcessMargin"`
+	VarMargin          Satoshi   `json:"varMargin"`
+	RealisedPnl        Satoshi   `json:"realisedPnl"`
+	UnrealisedPnl      Satoshi   `json:"unrealisedPnl"`
+	IndicativeTax      Satoshi   `json:"indicativeTax"`
+	UnrealisedProfit   Satoshi   `json:"unrealisedProfit"`
+	SyntheticMargin    Satoshi   `json:"syntheticMargin"`
+	WalletBalance      Satoshi   `json:"walletBalance"`
+	MarginBalance      Satoshi   `json:"marginBalance"`
+	MarginBalancePcnt  float64   `json:"marginBalancePcnt"`
+	MarginLeverage     float64   `json:"marginLeverage"`
+	MarginUsedPcnt     float64   `json:"marginUsedPcnt"`
+	ExcessMargin       Satoshi   `json:"excessMargin"`
+	ExcessMarginPcnt   float64   `json:"excessMarginPcnt"`
+	AvailableMargin    Satoshi   `json:"availableMargin"`
+	WithdrawableMargin Satoshi   `json:"withdrawableMargin"`
+	GrossLastValue     Satoshi   `json:"grossLastValue"`
+	Commission         float64   `json:"commission"`
+	Timestamp          time.Time `json:"timestamp"`
+}
+
+// Transaction - wallet history entry, also used for wallet summary rows
+type Transaction struct {
+	TransactID     string    `json:"transactID"`
+	Account        int64     `json:"account"`
+	Currency       string    `json:"currency"`
+	TransactType   string    `json:"transactType"`
+	Symbol         Contract  `json:"symbol"`
+	Amount         Satoshi   `json:"amount"`
+	Fee            Satoshi   `json:"fee"`
+	TransactStatus string    `json:"transactStatus"`
+	Address        string    `json:"address"`
+	Tx             string    `json:"tx"`
+	Text           string    `json:"text"`
+	PendingDebit   Satoshi   `json:"pendingDebit"`
+	RealisedPnl    Satoshi   `json:"realisedPnl"`
+	UnrealisedPnl  Satoshi   `json:"unrealisedPnl"`
+	WalletBalance  Satoshi   `json:"walletBalance"`
+	MarginBalance  Satoshi   `json:"marginBalance"`
+	TransactTime   time.Time `json:"transactTime"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// User - account owner details
+type User struct {
+	ID          int64     `json:"id"`
+	OwnerID     int64     `json:"ownerId"`
+	Firstname   string    `json:"firstname"`
+	Lastname    string    `json:"lastname"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	Country     string    `json:"country"`
+	TFAEnabled  string    `json:"TFAEnabled"`
+	AffiliateID string    `json:"affiliateID"`
+	PgpPubKey   string    `json:"pgpPubKey"`
+	Created     time.Time `json:"created"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
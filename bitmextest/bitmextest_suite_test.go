@@ -0,0 +1,13 @@
+package bitmextest_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBitmextest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bitmextest Suite")
+}
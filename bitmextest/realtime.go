@@ -0,0 +1,343 @@
+package bitmextest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/santacruz123/bitmex-go"
+	"golang.org/x/net/websocket"
+)
+
+// tables requiring authentication
+var privateTables = map[string]bool{
+	"order":     true,
+	"position":  true,
+	"execution": true,
+	"margin":    true,
+	"wallet":    true,
+}
+
+var tableKeys = map[string][]string{
+	"order":          {"orderID"},
+	"position":       {"account", "symbol", "currency"},
+	"execution":      {"execID"},
+	"margin":         {"account", "currency"},
+	"wallet":         {"account", "currency"},
+	"instrument":     {"symbol"},
+	"orderBookL2":    {"symbol", "id", "side"},
+	"orderBookL2_25": {"symbol", "id", "side"},
+}
+
+var errNotAuthorized = errors.New("User requested an account-locked subscription but no authorization was provided.")
+
+type tableMessage struct {
+	Table  string            `json:"table"`
+	Action string            `json:"action"`
+	Keys   []string          `json:"keys,omitempty"`
+	Filter map[string]string `json:"filter,omitempty"`
+	Data   interface{}       `json:"data"`
+}
+
+type request struct {
+	Op   string          `json:"op"`
+	Args json.RawMessage `json:"args"`
+}
+
+// client - one websocket connection
+type client struct {
+	conn bitmex.Conn
+
+	mu     sync.Mutex
+	authed bool
+	topics map[string]bool
+}
+
+func (c *client) send(msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.WriteMessage([]byte(msg))
+}
+
+func (c *client) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[topic]
+}
+
+// subscribers - clients subscribed to topic or whole table
+func (s *Server) subscribers(table, topic string) []*client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res []*client
+	for c := range s.clients {
+		if c.subscribed(topic) || c.subscribed(table) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// wsConn - adapts x/net websocket of realtime endpoint
+type wsConn struct {
+	*websocket.Conn
+}
+
+func (c wsConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	err := websocket.Message.Receive(c.Conn, &msg)
+	return msg, err
+}
+
+func (c wsConn) WriteMessage(msg []byte) error {
+	return websocket.Message.Send(c.Conn, string(msg))
+}
+
+func (s *Server) realtime(conn *websocket.Conn) {
+	s.serve(wsConn{conn})
+}
+
+// serve - handles client connected by network or in memory
+func (s *Server) serve(conn bitmex.Conn) {
+	c := &client{conn: conn, topics: make(map[string]bool)}
+
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	info, _ := json.Marshal(infoMessage{
+		Info:      "Welcome to the BitMEX Realtime API.",
+		Version:   "bitmextest",
+		Timestamp: time.Now().UTC(),
+		Docs:      "https://www.bitmex.com/app/wsAPI",
+	})
+	c.send(string(info))
+
+	for {
+		raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		msg := string(raw)
+
+		if msg == "ping" {
+			c.send("pong")
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal([]byte(msg), &req); err != nil {
+			c.send(errorMessage(400, err, nil))
+			continue
+		}
+
+		s.handle(c, req)
+	}
+}
+
+func (s *Server) handle(c *client, req request) {
+	switch req.Op {
+	case "authKey", "authKeyExpires":
+		var args []interface{}
+		json.Unmarshal(req.Args, &args)
+		if len(args) != 3 {
+			c.send(errorMessage(400, errors.New("Invalid arguments"), req))
+			return
+		}
+
+		key, _ := args[0].(string)
+		stamp, _ := args[1].(float64)
+		sig, _ := args[2].(string)
+
+		var expires, nonce string
+		if req.Op == "authKeyExpires" {
+			expires = fmt.Sprintf("%d", int64(stamp))
+		} else {
+			nonce = fmt.Sprintf("%d", int64(stamp))
+		}
+
+		if err := s.verify("GET", "/realtime", key, expires, nonce, sig, nil); err != nil {
+			c.send(errorMessage(401, err, req))
+			return
+		}
+
+		c.mu.Lock()
+		c.authed = true
+		c.mu.Unlock()
+
+		c.send(successMessage("", "", req))
+
+	case "subscribe", "unsubscribe":
+		for _, topic := range topicArgs(req.Args) {
+			s.subscribe(c, req.Op, topic)
+		}
+
+	case "cancelAllAfter":
+		c.mu.Lock()
+		authed := c.authed
+		c.mu.Unlock()
+
+		if !authed {
+			c.send(errorMessage(401, errors.New("Not authorized."), req))
+			return
+		}
+
+		var timeout int64
+		json.Unmarshal(req.Args, &timeout)
+		c.send(cancelAllAfterMessage(timeout, req))
+
+	default:
+		c.send(errorMessage(400, fmt.Errorf("Unknown or unsupported command %s.", req.Op), req))
+	}
+}
+
+func (s *Server) subscribe(c *client, op, topic string) {
+	// every topic is confirmed by its own message echoing it as request
+	req := request{Op: op}
+	req.Args, _ = json.Marshal(topic)
+
+	table := topic
+	for i := range topic {
+		if topic[i] == ':' {
+			table = topic[:i]
+			break
+		}
+	}
+
+	c.mu.Lock()
+	if op == "subscribe" && privateTables[table] && !c.authed {
+		c.mu.Unlock()
+		c.send(errorMessage(401, errNotAuthorized, req))
+		return
+	}
+
+	if op == "unsubscribe" {
+		if !c.topics[topic] {
+			c.mu.Unlock()
+			c.send(errorMessage(400, fmt.Errorf("You weren't subscribed to %s.", topic), req))
+			return
+		}
+		delete(c.topics, topic)
+		c.mu.Unlock()
+
+		c.send(successMessage("unsubscribe", topic, req))
+		return
+	}
+
+	if c.topics[topic] {
+		c.mu.Unlock()
+		c.send(errorMessage(400, fmt.Errorf("You are already subscribed to this topic: %s", topic), req))
+		return
+	}
+	c.topics[topic] = true
+	c.mu.Unlock()
+
+	c.send(successMessage("subscribe", topic, req))
+
+	// server side tables start with image of their state
+	var data interface{}
+	switch table {
+	case "order":
+		data = s.Orders()
+	case "position":
+		data = s.getPositions()
+	default:
+		return
+	}
+
+	msg, _ := json.Marshal(tableMessage{
+		Table:  table,
+		Action: "partial",
+		Keys:   tableKeys[table],
+		Data:   data,
+	})
+	c.send(string(msg))
+}
+
+// topicArgs - subscription args are single topic or list of them
+func topicArgs(raw json.RawMessage) []string {
+	var many []string
+	if err := json.Unmarshal(raw, &many); err == nil {
+		return many
+	}
+
+	var one string
+	json.Unmarshal(raw, &one)
+	return []string{one}
+}
+
+// Messages are matched by client on their prefix, so field order matters
+
+type infoMessage struct {
+	Info      string    `json:"info"`
+	Version   string    `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Docs      string    `json:"docs"`
+	Heartbeat bool      `json:"heartbeatEnabled"`
+}
+
+type successReply struct {
+	Success     bool        `json:"success"`
+	Subscribe   string      `json:"subscribe,omitempty"`
+	Unsubscribe string      `json:"unsubscribe,omitempty"`
+	Request     interface{} `json:"request"`
+}
+
+type errorReply struct {
+	Status  int                    `json:"status"`
+	Error   string                 `json:"error"`
+	Meta    map[string]interface{} `json:"meta"`
+	Request interface{}            `json:"request,omitempty"`
+}
+
+func successMessage(op, topic string, req interface{}) string {
+	msg := successReply{Success: true, Request: req}
+	switch op {
+	case "subscribe":
+		msg.Subscribe = topic
+	case "unsubscribe":
+		msg.Unsubscribe = topic
+	}
+
+	b, _ := json.Marshal(msg)
+	return string(b)
+}
+
+type cancelAllAfterReply struct {
+	Now        time.Time   `json:"now"`
+	CancelTime interface{} `json:"cancelTime"`
+	Request    interface{} `json:"request"`
+}
+
+// cancelAllAfterMessage - reply with time orders get cancelled, zero when
+// timeout disarms it
+func cancelAllAfterMessage(timeout int64, req interface{}) string {
+	now := time.Now().UTC()
+	msg := cancelAllAfterReply{Now: now, CancelTime: 0, Request: req}
+	if timeout > 0 {
+		msg.CancelTime = now.Add(time.Duration(timeout) * time.Millisecond)
+	}
+
+	b, _ := json.Marshal(msg)
+	return string(b)
+}
+
+func errorMessage(status int, err error, req interface{}) string {
+	b, _ := json.Marshal(errorReply{
+		Status:  status,
+		Error:   err.Error(),
+		Meta:    map[string]interface{}{},
+		Request: req,
+	})
+	return string(b)
+}
@@ -0,0 +1,481 @@
+package bitmextest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/santacruz123/bitmex-go"
+	uuid "github.com/satori/go.uuid"
+)
+
+// apiError - error response in BitMEX format
+type apiError struct {
+	status int
+	err    error
+}
+
+func (s *Server) rest(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.verify(
+		req.Method, req.RequestURI,
+		req.Header.Get("api-key"),
+		req.Header.Get("api-expires"),
+		req.Header.Get("api-nonce"),
+		req.Header.Get("api-signature"),
+		body,
+	); err != nil {
+		writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	res, apiErr := s.route(req, body)
+	if apiErr != nil {
+		writeError(w, apiErr.status, apiErr.err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) route(req *http.Request, body []byte) (interface{}, *apiError) {
+	path := strings.TrimPrefix(req.URL.Path, apiVersion)
+
+	switch req.Method + " " + path {
+	case "GET /order":
+		return s.getOrders(req)
+	case "POST /order":
+		var o bitmex.Order
+		if err := json.Unmarshal(body, &o); err != nil {
+			return nil, badRequest(err)
+		}
+		return s.placeOrders([]bitmex.Order{o}, true)
+	case "POST /order/bulk":
+		var bulk struct{ Orders []bitmex.Order }
+		if err := json.Unmarshal(body, &bulk); err != nil {
+			return nil, badRequest(err)
+		}
+		return s.placeOrders(bulk.Orders, false)
+	case "PUT /order":
+		var o bitmex.Order
+		if err := json.Unmarshal(body, &o); err != nil {
+			return nil, badRequest(err)
+		}
+		return s.amendOrders([]bitmex.Order{o}, true)
+	case "PUT /order/bulk":
+		var bulk struct{ Orders []bitmex.Order }
+		if err := json.Unmarshal(body, &bulk); err != nil {
+			return nil, badRequest(err)
+		}
+		return s.amendOrders(bulk.Orders, false)
+	case "DELETE /order":
+		return s.cancelOrders(body)
+	case "DELETE /order/all":
+		return s.cancelAll(body)
+	case "POST /order/cancelAllAfter":
+		return map[string]time.Time{"now": time.Now()}, nil
+	case "GET /trade/bucketed":
+		return s.getBuckets(req)
+	case "GET /position":
+		return s.getPositions(), nil
+	case "POST /position/leverage",
+		"POST /position/isolate",
+		"POST /position/transferMargin",
+		"POST /position/riskLimit":
+		return s.changePosition(path, body)
+	}
+
+	return nil, &apiError{http.StatusNotFound, errNotFound}
+}
+
+func badRequest(err error) *apiError {
+	return &apiError{http.StatusBadRequest, err}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	name := "HTTPError"
+	if status == http.StatusBadRequest {
+		name = "ValidationError"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]map[string]string{
+		"error": {"message": err.Error(), "name": name},
+	})
+}
+
+func isOpen(o *bitmex.Order) bool {
+	return o.OrdStatus == "New" || o.OrdStatus == "PartiallyFilled"
+}
+
+func (s *Server) getOrders(req *http.Request) (interface{}, *apiError) {
+	query := req.URL.Query()
+
+	var filter map[string]interface{}
+	if f := query.Get("filter"); f != "" {
+		if err := json.Unmarshal([]byte(f), &filter); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+
+	symbol := bitmex.Contract(query.Get("symbol"))
+	reverse := query.Get("reverse") == "true"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := []bitmex.Order{}
+	for i := range s.orders {
+		o := s.orders[i]
+		if reverse {
+			o = s.orders[len(s.orders)-1-i]
+		}
+
+		if symbol != "" && o.Symbol != symbol {
+			continue
+		}
+		if open, ok := filter["open"].(bool); ok && open != isOpen(o) {
+			continue
+		}
+		if id, ok := filter["clOrdID"].(string); ok && id != o.ClOrdID {
+			continue
+		}
+		if id, ok := filter["orderID"].(string); ok && id != o.OrderID.String() {
+			continue
+		}
+
+		res = append(res, *o)
+	}
+
+	if count := query.Get("count"); count != "" {
+		var n int
+		json.Unmarshal([]byte(count), &n)
+		if n > 0 && n < len(res) {
+			res = res[:n]
+		}
+	}
+
+	return res, nil
+}
+
+// placeOrders - accepts orders, market orders are filled right away
+func (s *Server) placeOrders(orders []bitmex.Order, single bool) (interface{}, *apiError) {
+	now := time.Now().UTC()
+	res := make([]bitmex.Order, 0, len(orders))
+	var positions []bitmex.Position
+
+	s.mu.Lock()
+	for _, o := range orders {
+		if o.Symbol == "" {
+			s.mu.Unlock()
+			return nil, badRequest(errors.New("'symbol' is a required arg."))
+		}
+
+		if o.Side == "" {
+			o.Side = "Buy"
+			if o.OrderQty < 0 {
+				o.Side = "Sell"
+			}
+		}
+		if o.OrderQty < 0 {
+			o.OrderQty = -o.OrderQty
+		}
+
+		if o.OrdType == "" {
+			o.OrdType = bitmex.Market
+			if o.Price != 0 {
+				o.OrdType = bitmex.Limit
+			}
+		}
+		if o.OrdType != bitmex.Market && o.OrdType != bitmex.Limit {
+			s.mu.Unlock()
+			return nil, badRequest(errInvalidOrdType)
+		}
+
+		o.Account = Account
+		o.OrderID = s.newID()
+		o.Timestamp = now
+		o.TransactTime = now
+		o.OrdStatus = "New"
+		o.LeavesQty = o.OrderQty
+		o.WorkingIndicator = true
+
+		if o.OrdType == bitmex.Market {
+			price := o.Price
+			if p, ok := s.prices[o.Symbol]; ok {
+				price = p
+			}
+			positions = append(positions, s.fill(&o, price))
+		}
+
+		stored := o
+		s.orders = append(s.orders, &stored)
+		res = append(res, o)
+	}
+	s.mu.Unlock()
+
+	s.Insert("order", res)
+	if len(positions) > 0 {
+		s.Update("position", positions)
+	}
+
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+// fill - fills rest of order at price, must be called locked
+func (s *Server) fill(o *bitmex.Order, price float64) bitmex.Position {
+	qty := o.LeavesQty
+	o.OrdStatus = "Filled"
+	o.CumQty += qty
+	o.LeavesQty = 0
+	o.AvgPx = price
+	o.WorkingIndicator = false
+
+	p, ok := s.positions[o.Symbol]
+	if !ok {
+		p = &bitmex.Position{Account: Account, Symbol: o.Symbol, Currency: bitmex.XBt}
+		s.positions[o.Symbol] = p
+	}
+
+	if o.Side == "Sell" {
+		qty = -qty
+	}
+	p.CurrentQty += int64(qty)
+	p.IsOpen = p.CurrentQty != 0
+	p.AvgEntryPrice = price
+	p.Timestamp = o.TransactTime
+
+	return *p
+}
+
+// find - order by orderID or clOrdID, must be called locked
+func (s *Server) find(orderID uuid.UUID, clOrdID string) *bitmex.Order {
+	for _, o := range s.orders {
+		if orderID != uuid.Nil && o.OrderID == orderID {
+			return o
+		}
+		if orderID == uuid.Nil && clOrdID != "" && o.ClOrdID == clOrdID {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) amendOrders(orders []bitmex.Order, single bool) (interface{}, *apiError) {
+	var res []bitmex.Order
+
+	s.mu.Lock()
+	for _, amend := range orders {
+		o := s.find(amend.OrderID, amend.ClOrdID)
+		if o == nil {
+			s.mu.Unlock()
+			return nil, badRequest(errors.New("Invalid ordStatus"))
+		}
+		if !isOpen(o) {
+			s.mu.Unlock()
+			return nil, badRequest(errors.New("Invalid ordStatus"))
+		}
+
+		if amend.Price != 0 {
+			o.Price = amend.Price
+		}
+		if amend.OrderQty != 0 {
+			o.OrderQty = amend.OrderQty
+			o.LeavesQty = amend.OrderQty - o.CumQty
+		}
+		o.TransactTime = time.Now().UTC()
+		o.Timestamp = o.TransactTime
+
+		res = append(res, *o)
+	}
+	s.mu.Unlock()
+
+	s.Update("order", res)
+
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+// ids - decodes id which may be single value or array
+func ids(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(raw, &many); err == nil {
+		return many
+	}
+
+	var one string
+	json.Unmarshal(raw, &one)
+	if one == "" {
+		return nil
+	}
+	return []string{one}
+}
+
+func (s *Server) cancelOrders(body []byte) (interface{}, *apiError) {
+	var req struct {
+		OrderID json.RawMessage `json:"orderID"`
+		ClOrdID json.RawMessage `json:"clOrdID"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, badRequest(err)
+	}
+
+	var targets []*bitmex.Order
+	var missing []bitmex.Order
+
+	s.mu.Lock()
+	for _, id := range ids(req.OrderID) {
+		orderID, _ := uuid.FromString(id)
+		if orderID == uuid.Nil {
+			continue
+		}
+		if o := s.find(orderID, ""); o != nil {
+			targets = append(targets, o)
+		} else {
+			missing = append(missing, bitmex.Order{OrderID: orderID, Error: "Not Found"})
+		}
+	}
+	for _, id := range ids(req.ClOrdID) {
+		if o := s.find(uuid.Nil, id); o != nil {
+			targets = append(targets, o)
+		} else {
+			missing = append(missing, bitmex.Order{ClOrdID: id, Error: "Not Found"})
+		}
+	}
+
+	res, changed := s.cancel(targets)
+	s.mu.Unlock()
+
+	if len(res) == 0 && len(missing) > 0 {
+		return nil, badRequest(errors.New("Not Found"))
+	}
+
+	if len(changed) > 0 {
+		s.Update("order", changed)
+	}
+	return append(res, missing...), nil
+}
+
+func (s *Server) cancelAll(body []byte) (interface{}, *apiError) {
+	var req struct {
+		Symbol bitmex.Contract `json:"symbol"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+
+	s.mu.Lock()
+	var targets []*bitmex.Order
+	for _, o := range s.orders {
+		if isOpen(o) && (req.Symbol == "" || o.Symbol == req.Symbol) {
+			targets = append(targets, o)
+		}
+	}
+	res, changed := s.cancel(targets)
+	s.mu.Unlock()
+
+	if len(changed) > 0 {
+		s.Update("order", changed)
+	}
+	return res, nil
+}
+
+// cancel - cancels open orders, closed ones are reported with error, must be
+// called locked
+func (s *Server) cancel(orders []*bitmex.Order) (res, changed []bitmex.Order) {
+	res = []bitmex.Order{}
+	now := time.Now().UTC()
+
+	for _, o := range orders {
+		if !isOpen(o) {
+			one := *o
+			one.Error = "Unable to cancel order due to existing state: " + o.OrdStatus
+			res = append(res, one)
+			continue
+		}
+
+		o.OrdStatus = "Canceled"
+		o.LeavesQty = 0
+		o.WorkingIndicator = false
+		o.TransactTime = now
+		o.Timestamp = now
+
+		res = append(res, *o)
+		changed = append(changed, *o)
+	}
+	return res, changed
+}
+
+func (s *Server) getPositions() []bitmex.Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := []bitmex.Position{}
+	for _, p := range s.positions {
+		res = append(res, *p)
+	}
+	return res
+}
+
+func (s *Server) changePosition(path string, body []byte) (interface{}, *apiError) {
+	var req struct {
+		Symbol    bitmex.Contract `json:"symbol"`
+		Leverage  float64         `json:"leverage"`
+		Enabled   bool            `json:"enabled"`
+		Amount    int64           `json:"amount"`
+		RiskLimit int64           `json:"riskLimit"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, badRequest(err)
+	}
+	if req.Symbol == "" {
+		return nil, badRequest(errors.New("'symbol' is a required arg."))
+	}
+
+	s.mu.Lock()
+	p, ok := s.positions[req.Symbol]
+	if !ok {
+		p = &bitmex.Position{Account: Account, Symbol: req.Symbol, Currency: bitmex.XBt, CrossMargin: true}
+		s.positions[req.Symbol] = p
+	}
+
+	switch path {
+	case "/position/leverage":
+		p.Leverage = req.Leverage
+		p.CrossMargin = req.Leverage == 0
+	case "/position/isolate":
+		p.CrossMargin = !req.Enabled
+	case "/position/transferMargin":
+		p.PosMargin += req.Amount
+	case "/position/riskLimit":
+		p.RiskLimit = req.RiskLimit
+	}
+	p.Timestamp = time.Now().UTC()
+
+	res := *p
+	s.mu.Unlock()
+
+	s.Update("position", []bitmex.Position{res})
+	return res, nil
+}
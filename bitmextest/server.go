@@ -0,0 +1,267 @@
+// Package bitmextest provides in-process fake of BitMEX REST and realtime
+// API for testing clients without network and real credentials.
+//
+//	srv := bitmextest.NewServer("key", "secret")
+//	defer srv.Close()
+//
+//	rest := bitmex.NewREST(bitmex.WithBaseURL(srv.URL))
+//	rest.Auth("key", "secret")
+//	ws := bitmex.NewWS(bitmex.WithBaseURL(srv.URL))
+package bitmextest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/santacruz123/bitmex-go"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/net/websocket"
+)
+
+const apiVersion = "/api/v1"
+
+// Account - account number of orders and positions created by server
+const Account = 1
+
+var (
+	errMissingKey     = errors.New("Missing API key.")
+	errInvalidKey     = errors.New("Invalid API Key.")
+	errExpired        = errors.New("This request has expired - `expires` is in the past.")
+	errNonce          = errors.New("Nonce is not increasing.")
+	errSignature      = errors.New("Signature not valid.")
+	errNotFound       = errors.New("Not Found")
+	errInvalidOrdType = errors.New("Invalid ordType")
+)
+
+// Server - fake BitMEX listening on local address, URL is passed to client
+// with bitmex.WithBaseURL. Requests are accepted only when signed with Key
+// and Secret, either by api-expires or legacy api-nonce scheme
+type Server struct {
+	*httptest.Server
+
+	Key, Secret string
+
+	mu        sync.Mutex
+	nonce     int64
+	lastID    uint64
+	prices    map[bitmex.Contract]float64
+	orders    []*bitmex.Order
+	positions map[bitmex.Contract]*bitmex.Position
+	candles   map[bitmex.BinSize][]bitmex.Candle
+	clients   map[*client]struct{}
+}
+
+// NewServer - starts server accepting credentials key and secret
+func NewServer(key, secret string) *Server {
+	s := &Server{
+		Key:       key,
+		Secret:    secret,
+		prices:    make(map[bitmex.Contract]float64),
+		positions: make(map[bitmex.Contract]*bitmex.Position),
+		candles:   make(map[bitmex.BinSize][]bitmex.Candle),
+		clients:   make(map[*client]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/realtime", websocket.Handler(s.realtime))
+	mux.HandleFunc(apiVersion+"/", s.rest)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Close - drops websocket clients and shuts server down
+func (s *Server) Close() {
+	s.DropConnections()
+	s.Server.Close()
+}
+
+// SetPrice - price market orders of symbol are filled at, without it they
+// are filled at their own price
+func (s *Server) SetPrice(symbol bitmex.Contract, price float64) {
+	s.mu.Lock()
+	s.prices[symbol] = price
+	s.mu.Unlock()
+}
+
+// SetPosition - replaces position of symbol and publishes it
+func (s *Server) SetPosition(p bitmex.Position) {
+	s.mu.Lock()
+	if p.Account == 0 {
+		p.Account = Account
+	}
+	s.positions[p.Symbol] = &p
+	s.mu.Unlock()
+
+	s.Update("position", []bitmex.Position{p})
+}
+
+// Orders - snapshot of all orders server knows, oldest first
+func (s *Server) Orders() []bitmex.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]bitmex.Order, 0, len(s.orders))
+	for _, one := range s.orders {
+		res = append(res, *one)
+	}
+	return res
+}
+
+// Position - current position of symbol
+func (s *Server) Position(symbol bitmex.Contract) (bitmex.Position, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.positions[symbol]
+	if !ok {
+		return bitmex.Position{}, false
+	}
+	return *p, true
+}
+
+// newID - sequential order id, must be called locked
+func (s *Server) newID() uuid.UUID {
+	s.lastID++
+
+	var id uuid.UUID
+	binary.BigEndian.PutUint64(id[8:], s.lastID)
+	return id
+}
+
+// verify - checks credentials of request signed over verb + path + expires
+// (or nonce) + data
+func (s *Server) verify(verb, path, key, expires, nonce, sig string, data []byte) error {
+	if key == "" {
+		return errMissingKey
+	}
+	if key != s.Key {
+		return errInvalidKey
+	}
+
+	var stamp string
+	switch {
+	case expires != "":
+		n, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || n < time.Now().Unix() {
+			return errExpired
+		}
+		stamp = expires
+
+	case nonce != "":
+		n, err := strconv.ParseInt(nonce, 10, 64)
+		if err != nil {
+			return errNonce
+		}
+
+		s.mu.Lock()
+		ok := n > s.nonce
+		if ok {
+			s.nonce = n
+		}
+		s.mu.Unlock()
+
+		if !ok {
+			return errNonce
+		}
+		stamp = nonce
+
+	default:
+		return errExpired
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	fmt.Fprintf(mac, "%s%s%s", verb, path, stamp)
+	mac.Write(data)
+
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(sig)) {
+		return errSignature
+	}
+	return nil
+}
+
+// Publish - sends table message to clients subscribed to topic ("order",
+// "trade:XBTUSD") or to its whole table. Data is usually slice of rows.
+// Messages of per-contract topic carry its symbol as filter
+func (s *Server) Publish(topic, action string, data interface{}) {
+	table := topic
+	var filter map[string]string
+	for i := range topic {
+		if topic[i] == ':' {
+			table = topic[:i]
+			filter = map[string]string{"symbol": topic[i+1:]}
+			break
+		}
+	}
+
+	msg, err := json.Marshal(tableMessage{
+		Table:  table,
+		Action: action,
+		Keys:   tableKeys[table],
+		Filter: filter,
+		Data:   data,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	for _, c := range s.subscribers(table, topic) {
+		c.send(string(msg))
+	}
+}
+
+// Partial - publishes initial image of topic
+func (s *Server) Partial(topic string, data interface{}) {
+	s.Publish(topic, "partial", data)
+}
+
+// Insert - publishes new rows of topic
+func (s *Server) Insert(topic string, data interface{}) {
+	s.Publish(topic, "insert", data)
+}
+
+// Update - publishes changed rows of topic
+func (s *Server) Update(topic string, data interface{}) {
+	s.Publish(topic, "update", data)
+}
+
+// Delete - publishes removed rows of topic
+func (s *Server) Delete(topic string, data interface{}) {
+	s.Publish(topic, "delete", data)
+}
+
+// Subscribed - whether any client is subscribed to topic
+func (s *Server) Subscribed(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.clients {
+		if c.subscribed(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// DropConnections - closes all websocket connections, e.g. to test
+// reconnection
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	clients := s.clients
+	s.clients = make(map[*client]struct{})
+	s.mu.Unlock()
+
+	for c := range clients {
+		c.conn.Close()
+	}
+}
@@ -0,0 +1,186 @@
+package bitmextest_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("Server", func() {
+	var srv *bitmextest.Server
+	var rest *bitmex.REST
+
+	BeforeEach(func() {
+		srv = bitmextest.NewServer("key", "secret")
+		rest = bitmex.NewREST(bitmex.WithBaseURL(srv.URL))
+		rest.Auth("key", "secret")
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Context("REST", func() {
+		It("Should reject invalid signature", func() {
+			rest.Auth("key", "wrong")
+
+			_, err := rest.Orders(nil)
+			Expect(err).To(BeAssignableToTypeOf(&bitmex.APIError{}))
+			Expect(err.(*bitmex.APIError).StatusCode).To(Equal(401))
+			Expect(err.(*bitmex.APIError).Message).To(Equal("Signature not valid."))
+		})
+
+		It("Should accept legacy nonce", func() {
+			legacy := bitmex.NewREST(bitmex.WithBaseURL(srv.URL), bitmex.WithLegacyNonce())
+			legacy.Auth("key", "secret")
+
+			_, err := legacy.Orders(nil)
+			Expect(err).To(Succeed())
+		})
+
+		It("Should manage orders", func() {
+			o, err := rest.LimitBuyOrder("XBTUSD", 6500, 100, true)
+			Expect(err).To(Succeed())
+			Expect(o.OrdStatus).To(Equal("New"))
+			Expect(o.LeavesQty).To(Equal(100.0))
+
+			o.Price = 6400
+			amended, err := rest.ModifyOrder(bitmex.Order{OrderID: o.OrderID, Price: 6400})
+			Expect(err).To(Succeed())
+			Expect(amended.Price).To(Equal(6400.0))
+
+			open, err := rest.Orders(&bitmex.Query{Filter: map[string]interface{}{"open": true}})
+			Expect(err).To(Succeed())
+			Expect(open).To(HaveLen(1))
+
+			Expect(rest.CancelOrder(o.OrderID)).To(Succeed())
+
+			open, err = rest.Orders(&bitmex.Query{Filter: map[string]interface{}{"open": true}})
+			Expect(err).To(Succeed())
+			Expect(open).To(BeEmpty())
+		})
+
+		It("Should fill market orders into position", func() {
+			srv.SetPrice(bitmex.XBTUSD, 6500)
+
+			o, err := rest.OrderSend(bitmex.NewOrderMarket(bitmex.XBTUSD, -10))
+			Expect(err).To(Succeed())
+			Expect(o.OrdStatus).To(Equal("Filled"))
+			Expect(o.Side).To(Equal("Sell"))
+			Expect(o.AvgPx).To(Equal(6500.0))
+
+			_, err = rest.SetLeverage(bitmex.XBTUSD, 10)
+			Expect(err).To(Succeed())
+
+			positions, err := rest.Positions(nil)
+			Expect(err).To(Succeed())
+			Expect(positions).To(HaveLen(1))
+			Expect(positions[0].CurrentQty).To(BeEquivalentTo(-10))
+			Expect(positions[0].Leverage).To(Equal(10.0))
+		})
+	})
+
+	Context("Realtime", func() {
+		var ws *bitmex.WS
+		var ctx context.Context
+		var cancel context.CancelFunc
+
+		BeforeEach(func() {
+			ws = bitmex.NewWS(bitmex.WithBaseURL(srv.URL))
+			Expect(ws.Connect()).To(Succeed())
+
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		})
+
+		AfterEach(func() {
+			cancel()
+			ws.Disconnect()
+		})
+
+		It("Should require authentication for private tables", func() {
+			err := ws.SubOrderContext(ctx, make(chan bitmex.Order, 1), nil)
+			Expect(err).To(MatchError(ContainSubstring("no authorization was provided")))
+			Expect(srv.Subscribed("order")).To(BeFalse())
+		})
+
+		It("Should stream orders placed over REST", func() {
+			Expect(ws.AuthContext(ctx, "key", "secret")).To(Succeed())
+
+			orders := make(chan bitmex.Order, 4)
+			Expect(ws.SubOrderContext(ctx, orders, nil)).To(Succeed())
+
+			o, err := rest.LimitSellOrder("XBTUSD", 7000, 5, false)
+			Expect(err).To(Succeed())
+
+			var got bitmex.Order
+			Eventually(orders).Should(Receive(&got))
+			Expect(got.OrderID).To(Equal(o.OrderID))
+			Expect(got.Side).To(Equal("Sell"))
+		})
+
+		It("Should reject duplicate and unknown subscriptions", func() {
+			conn, err := srv.Dialer().Dial(ctx, "")
+			Expect(err).To(Succeed())
+			defer conn.Close()
+
+			reply := func(req string) string {
+				Expect(conn.WriteMessage([]byte(req))).To(Succeed())
+				msg, err := conn.ReadMessage()
+				Expect(err).To(Succeed())
+				return string(msg)
+			}
+
+			welcome, err := conn.ReadMessage()
+			Expect(err).To(Succeed())
+			Expect(string(welcome)).To(HavePrefix(`{"info"`))
+
+			Expect(reply(`{"op":"subscribe","args":"trade:XBTUSD"}`)).To(HavePrefix(`{"success"`))
+			msg := reply(`{"op":"subscribe","args":"trade:XBTUSD"}`)
+			Expect(msg).To(HavePrefix(`{"status":400`))
+			Expect(msg).To(ContainSubstring("already subscribed"))
+
+			Expect(reply(`{"op":"unsubscribe","args":"trade:XBTUSD"}`)).To(HavePrefix(`{"success"`))
+			msg = reply(`{"op":"unsubscribe","args":"trade:XBTUSD"}`)
+			Expect(msg).To(HavePrefix(`{"status":400`))
+			Expect(msg).To(ContainSubstring("weren't subscribed"))
+		})
+
+		It("Should publish scripted table messages", func() {
+			trades := make(chan bitmex.WSTrade, 4)
+			Expect(ws.SubTradeContext(ctx, trades, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+
+			srv.Insert("trade:XBTUSD", []map[string]interface{}{
+				{"symbol": "XBTUSD", "side": "Buy", "size": 10, "price": 6500.5},
+			})
+			srv.Insert("trade:ETHUSD", []map[string]interface{}{
+				{"symbol": "ETHUSD", "side": "Buy", "size": 1, "price": 300},
+			})
+
+			var trade bitmex.WSTrade
+			Eventually(trades).Should(Receive(&trade))
+			Expect(trade.Price).To(Equal(6500.5))
+			Consistently(trades, 100*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("Should maintain order book from partial and update", func() {
+			Expect(ws.SubOrderBookContext(ctx, nil, bitmex.OrderBookL2, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+
+			srv.Partial("orderBookL2:XBTUSD", []map[string]interface{}{
+				{"symbol": "XBTUSD", "id": 1, "side": "Sell", "size": 10, "price": 6501},
+				{"symbol": "XBTUSD", "id": 2, "side": "Buy", "size": 20, "price": 6500},
+			})
+			srv.Update("orderBookL2:XBTUSD", []map[string]interface{}{
+				{"symbol": "XBTUSD", "id": 2, "side": "Buy", "size": 5},
+			})
+
+			Eventually(func() float64 {
+				bid, _ := ws.OrderBook(bitmex.XBTUSD).BestBid()
+				return bid.Size
+			}).Should(Equal(5.0))
+		})
+	})
+})
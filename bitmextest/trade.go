@@ -0,0 +1,91 @@
+package bitmextest
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/santacruz123/bitmex-go"
+)
+
+// maxCount - row limit of GET requests
+const maxCount = 1000
+
+// AddCandles - stores history served by GET /trade/bucketed, candles are
+// kept sorted by timestamp
+func (s *Server) AddCandles(binSize bitmex.BinSize, candles ...bitmex.Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := append(s.candles[binSize], candles...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Timestamp.Before(all[j].Timestamp)
+	})
+	s.candles[binSize] = all
+}
+
+func (s *Server) getBuckets(req *http.Request) (interface{}, *apiError) {
+	query := req.URL.Query()
+
+	binSize := bitmex.BinSize(query.Get("binSize"))
+	if binSize.Duration() == 0 {
+		return nil, badRequest(errors.New("Invalid binSize."))
+	}
+
+	var startTime, endTime time.Time
+	for name, t := range map[string]*time.Time{"startTime": &startTime, "endTime": &endTime} {
+		if v := query.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, badRequest(err)
+			}
+			*t = parsed
+		}
+	}
+
+	count := 100
+	if v := query.Get("count"); v != "" {
+		count, _ = strconv.Atoi(v)
+	}
+	if count <= 0 || count > maxCount {
+		return nil, badRequest(errors.New("count must be between 1 and 1000"))
+	}
+	start, _ := strconv.Atoi(query.Get("start"))
+
+	symbol := bitmex.Contract(query.Get("symbol"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := []bitmex.Candle{}
+	for _, c := range s.candles[binSize] {
+		if symbol != "" && c.Symbol != symbol {
+			continue
+		}
+		if !startTime.IsZero() && c.Timestamp.Before(startTime) {
+			continue
+		}
+		if !endTime.IsZero() && c.Timestamp.After(endTime) {
+			continue
+		}
+		res = append(res, c)
+	}
+
+	if query.Get("reverse") == "true" {
+		for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+			res[i], res[j] = res[j], res[i]
+		}
+	}
+
+	if start >= len(res) {
+		return []bitmex.Candle{}, nil
+	}
+	res = res[start:]
+	if count < len(res) {
+		res = res[:count]
+	}
+
+	return res, nil
+}
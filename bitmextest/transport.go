@@ -0,0 +1,133 @@
+package bitmextest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/santacruz123/bitmex-go"
+)
+
+// ErrDeadline - read deadline of MemConn passed
+var ErrDeadline = errors.New("bitmextest: read deadline exceeded")
+
+// MemConn - one end of in-memory websocket connection implementing
+// bitmex.Conn. Deadline applies to reads started after it is set
+type MemConn struct {
+	in  chan []byte
+	out chan []byte
+
+	closed chan struct{}
+	once   *sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// Pipe - connected pair of in-memory connections, closing either closes both
+func Pipe() (*MemConn, *MemConn) {
+	a2b := make(chan []byte, 64)
+	b2a := make(chan []byte, 64)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+
+	return &MemConn{in: b2a, out: a2b, closed: closed, once: once},
+		&MemConn{in: a2b, out: b2a, closed: closed, once: once}
+}
+
+// ReadMessage - next message from other end, io.EOF once closed
+func (c *MemConn) ReadMessage() ([]byte, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case msg := <-c.in:
+		return msg, nil
+	case <-c.closed:
+		return nil, io.EOF
+	case <-timeout:
+		return nil, ErrDeadline
+	}
+}
+
+// WriteMessage - sends message to other end
+func (c *MemConn) WriteMessage(msg []byte) error {
+	select {
+	case <-c.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+// SetReadDeadline - zero time disables deadline
+func (c *MemConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return nil
+}
+
+// Close - closes both ends
+func (c *MemConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// Transport - in-memory bitmex.Dialer, tests accept server ends of dialed
+// connections and script them
+type Transport struct {
+	conns chan *MemConn
+}
+
+// NewTransport - creates transport, pass it to bitmex.WithDialer
+func NewTransport() *Transport {
+	return &Transport{conns: make(chan *MemConn, 16)}
+}
+
+// Dial - returns client end of new pipe, server end waits in Accept
+func (t *Transport) Dial(ctx context.Context, url string) (bitmex.Conn, error) {
+	client, server := Pipe()
+
+	select {
+	case t.conns <- server:
+		return client, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Accept - server end of next dialed connection
+func (t *Transport) Accept(ctx context.Context) (*MemConn, error) {
+	select {
+	case conn := <-t.conns:
+		return conn, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Dialer - connects WS to server in memory, without network
+func (s *Server) Dialer() bitmex.Dialer {
+	return bitmex.DialerFunc(func(ctx context.Context, url string) (bitmex.Conn, error) {
+		client, server := Pipe()
+		go s.serve(server)
+		return client, nil
+	})
+}
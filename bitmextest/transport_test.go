@@ -0,0 +1,85 @@
+package bitmextest_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("Transport", func() {
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("Should script connection in memory", func() {
+		tr := bitmextest.NewTransport()
+		ws := bitmex.NewWS(bitmex.WithDialer(tr), bitmex.WithHeartbeat(0, 0))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		server, err := tr.Accept(ctx)
+		Expect(err).To(Succeed())
+
+		quotes := make(chan bitmex.WSQuote, 1)
+		Expect(ws.SubQuote(quotes, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+
+		msg, err := server.ReadMessage()
+		Expect(err).To(Succeed())
+		Expect(string(msg)).To(Equal(`{"op": "subscribe", "args": "quote:XBTUSD"}`))
+
+		server.WriteMessage([]byte(`{"table":"quote","action":"insert","data":[{"symbol":"XBTUSD","bidPrice":6500,"askPrice":6500.5}]}`))
+
+		var quote bitmex.WSQuote
+		Eventually(quotes).Should(Receive(&quote))
+		Expect(quote.AskPrice).To(Equal(6500.5))
+	})
+
+	It("Should reconnect when transport drops", func() {
+		tr := bitmextest.NewTransport()
+		ws := bitmex.NewWS(
+			bitmex.WithDialer(tr),
+			bitmex.WithHeartbeat(0, 0),
+			bitmex.WithReconnect(time.Millisecond, time.Millisecond),
+		)
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		first, err := tr.Accept(ctx)
+		Expect(err).To(Succeed())
+		first.Close()
+
+		_, err = tr.Accept(ctx)
+		Expect(err).To(Succeed())
+	})
+
+	It("Should connect to server in memory", func() {
+		srv := bitmextest.NewServer("key", "secret")
+		defer srv.Close()
+
+		ws := bitmex.NewWS(bitmex.WithDialer(srv.Dialer()))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		Expect(ws.AuthContext(ctx, "key", "secret")).To(Succeed())
+
+		positions := make(chan bitmex.Position, 1)
+		Expect(ws.SubPositionContext(ctx, positions, nil)).To(Succeed())
+
+		srv.SetPosition(bitmex.Position{Symbol: bitmex.XBTUSD, CurrentQty: 100})
+
+		var p bitmex.Position
+		Eventually(positions).Should(Receive(&p))
+		Expect(p.CurrentQty).To(BeEquivalentTo(100))
+	})
+})
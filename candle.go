@@ -0,0 +1,109 @@
+package bitmex
+
+import (
+	"context"
+	"time"
+)
+
+// BinSize - candle interval
+type BinSize string
+
+// Candle intervals supported by BitMEX
+const (
+	Bin1m BinSize = "1m"
+	Bin5m BinSize = "5m"
+	Bin1h BinSize = "1h"
+	Bin1d BinSize = "1d"
+)
+
+// bucketedPage - max rows of one GET /trade/bucketed response
+const bucketedPage = 1000
+
+// Duration - length of interval, zero if unknown
+func (b BinSize) Duration() time.Duration {
+	switch b {
+	case Bin1m:
+		return time.Minute
+	case Bin5m:
+		return 5 * time.Minute
+	case Bin1h:
+		return time.Hour
+	case Bin1d:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// Candle - OHLCV bin, Timestamp is the close time of the bin
+type Candle struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Symbol          Contract  `json:"symbol"`
+	Open            float64   `json:"open"`
+	High            float64   `json:"high"`
+	Low             float64   `json:"low"`
+	Close           float64   `json:"close"`
+	Trades          int64     `json:"trades"`
+	Volume          int64     `json:"volume"`
+	Vwap            float64   `json:"vwap"`
+	LastSize        int64     `json:"lastSize"`
+	Turnover        int64     `json:"turnover"`
+	HomeNotional    float64   `json:"homeNotional"`
+	ForeignNotional float64   `json:"foreignNotional"`
+}
+
+// TradeBuckets - candles of symbol between start and end (zero for open
+// ended), oldest first. Requests are repeated until all pages over 1000 rows
+// limit are loaded. With partial the current unfinished bin is included
+func (r *REST) TradeBuckets(symbol Contract, binSize BinSize, start, end time.Time, partial bool) ([]Candle, error) {
+	return r.TradeBucketsContext(context.Background(), symbol, binSize, start, end, partial)
+}
+
+// TradeBucketsContext - TradeBuckets with context
+func (r *REST) TradeBucketsContext(ctx context.Context, symbol Contract, binSize BinSize, start, end time.Time, partial bool) ([]Candle, error) {
+	q := &Query{
+		Symbol:    symbol,
+		Count:     bucketedPage,
+		StartTime: start,
+		EndTime:   end,
+	}
+
+	var candles []Candle
+
+	for {
+		v, err := q.Values()
+		if err != nil {
+			return candles, err
+		}
+		v.Set("binSize", string(binSize))
+		if partial {
+			v.Set("partial", "true")
+		}
+
+		var page []Candle
+		if err := r.get(ctx, "/trade/bucketed", v, &page); err != nil {
+			return candles, err
+		}
+		candles = append(candles, page...)
+
+		if len(page) < bucketedPage {
+			return candles, nil
+		}
+		// Large offsets are slow, next page starts after last candle instead
+		q.StartTime = page[len(page)-1].Timestamp.Add(time.Millisecond)
+	}
+}
+
+// tradeBinTopic - websocket table of interval
+func tradeBinTopic(binSize BinSize) string {
+	return "tradeBin" + string(binSize)
+}
+
+// binSizeOf - interval of websocket table, false if table is not tradeBin
+func binSizeOf(table string) (BinSize, bool) {
+	for _, one := range []BinSize{Bin1m, Bin5m, Bin1h, Bin1d} {
+		if table == tradeBinTopic(one) {
+			return one, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,103 @@
+package bitmex_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+// roundTripFunc - function implementing http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Candles", func() {
+	var srv *bitmextest.Server
+
+	BeforeEach(func() {
+		srv = bitmextest.NewServer("key", "secret")
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Should load trade buckets over several pages", func() {
+		from := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		var history []bitmex.Candle
+		for i := 0; i < 2500; i++ {
+			history = append(history, bitmex.Candle{
+				Timestamp: from.Add(time.Duration(i) * time.Minute),
+				Symbol:    bitmex.XBTUSD,
+				Close:     float64(6000 + i),
+			})
+		}
+		srv.AddCandles(bitmex.Bin1m, history...)
+		srv.AddCandles(bitmex.Bin1m, bitmex.Candle{Timestamp: from, Symbol: "ETHUSD"})
+
+		var mu sync.Mutex
+		var queries []url.Values
+		client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			queries = append(queries, req.URL.Query())
+			mu.Unlock()
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+
+		rest := bitmex.NewREST(bitmex.WithBaseURL(srv.URL), bitmex.WithHTTPClient(client))
+		rest.Auth("key", "secret")
+
+		candles, err := rest.TradeBuckets(bitmex.XBTUSD, bitmex.Bin1m, from, time.Time{}, false)
+		Expect(err).To(Succeed())
+		Expect(candles).To(HaveLen(2500))
+
+		// Pages are requested by time, not by growing offset
+		mu.Lock()
+		Expect(queries).To(HaveLen(3))
+		for _, q := range queries {
+			Expect(q.Get("start")).To(BeEmpty())
+		}
+		Expect(queries[1].Get("startTime")).To(Equal("2018-01-01T16:39:00.001Z"))
+		mu.Unlock()
+		Expect(candles[0].Close).To(Equal(6000.0))
+		Expect(candles[2499].Close).To(Equal(8499.0))
+
+		end := from.Add(1999 * time.Minute)
+		candles, err = rest.TradeBuckets(bitmex.XBTUSD, bitmex.Bin1m, from.Add(time.Minute), end, false)
+		Expect(err).To(Succeed())
+		Expect(candles).To(HaveLen(1999))
+		Expect(candles[1998].Timestamp).To(BeTemporally("==", end))
+	})
+
+	It("Should stream trade bins of subscribed contracts", func() {
+		ws := bitmex.NewWS(bitmex.WithBaseURL(srv.URL))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		candles := make(chan bitmex.Candle, 2)
+		Expect(ws.SubTradeBinContext(ctx, bitmex.Bin5m, candles, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+
+		srv.Insert("tradeBin5m:ETHUSD", []bitmex.Candle{{Symbol: "ETHUSD", Close: 300}})
+		srv.Insert("tradeBin1m:XBTUSD", []bitmex.Candle{{Symbol: bitmex.XBTUSD, Close: 6400}})
+		srv.Insert("tradeBin5m:XBTUSD", []bitmex.Candle{{Symbol: bitmex.XBTUSD, Open: 6490, Close: 6500, Volume: 1000}})
+
+		var c bitmex.Candle
+		Eventually(candles).Should(Receive(&c))
+		Expect(c.Close).To(Equal(6500.0))
+		Expect(c.Volume).To(BeEquivalentTo(1000))
+		Consistently(candles, 100*time.Millisecond).ShouldNot(Receive())
+	})
+})
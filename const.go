@@ -3,7 +3,8 @@ package bitmex
 // Contract type
 type Contract string
 
-// Currently active contracts on BitMEX
+// Contracts listed at the time of writing, most are expired by now,
+// use Instruments for contracts which are currently active
 const (
 	XBTUSD Contract = "XBTUSD"
 	XBTM16          = "XBTM16"
@@ -0,0 +1,217 @@
+package bitmex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// Errors of DeadMansSwitch state changes
+var (
+	ErrSwitchInterval = errors.New("bitmex: dead man's switch interval has to be positive and shorter than timeout")
+	ErrSwitchStarted  = errors.New("bitmex: dead man's switch already started")
+	ErrSwitchStopped  = errors.New("bitmex: dead man's switch stopped, it can't be restarted")
+)
+
+// Canceller - cancels all orders unless called again within timeout,
+// zero timeout disarms it. Implemented by REST and WS
+type Canceller interface {
+	CancelAllAfterContext(ctx context.Context, timeout time.Duration) error
+}
+
+// CancelAllAfter - arms dead man's switch, all orders are cancelled unless
+// it is called again within timeout, zero timeout disarms it
+func (r *REST) CancelAllAfter(timeout time.Duration) error {
+	return r.CancelAllAfterContext(context.Background(), timeout)
+}
+
+// CancelAllAfterContext - CancelAllAfter with context
+func (r *REST) CancelAllAfterContext(ctx context.Context, timeout time.Duration) error {
+	body, err := json.Marshal(map[string]int64{
+		"timeout": int64(timeout / time.Millisecond),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := r.requestContext(ctx, "POST", "/order/cancelAllAfter", body)
+	if err != nil {
+		return err
+	}
+
+	return r.do(req, nil)
+}
+
+// cancelAllAfterConfirm - wait for reply nobody waits for, after which
+// missing confirmation is reported
+const cancelAllAfterConfirm = 10 * time.Second
+
+//CancelAllAfter - arms dead man's switch over authenticated websocket,
+//returns once request is sent. Rejection by server, or no reply within
+//cancelAllAfterConfirm, is reported to Errors
+func (ws *WS) CancelAllAfter(timeout time.Duration) error {
+	sent := make(chan error, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cancelAllAfterConfirm)
+		defer cancel()
+
+		var sendErr error
+		err := ws.await(ctx, "cancelAllAfter", func() error {
+			sendErr = ws.send(cancelAllAfterMessage(timeout))
+			sent <- sendErr
+			return sendErr
+		})
+
+		select {
+		case <-ws.quit:
+		default:
+			// send failure is returned to caller
+			if err != nil && sendErr == nil {
+				ws.error(fmt.Errorf("WS cancelAllAfter: %v", err))
+			}
+		}
+	}()
+
+	return <-sent
+}
+
+//CancelAllAfterContext - CancelAllAfter waiting for server confirmation
+func (ws *WS) CancelAllAfterContext(ctx context.Context, timeout time.Duration) error {
+	return ws.await(ctx, "cancelAllAfter", func() error {
+		return ws.send(cancelAllAfterMessage(timeout))
+	})
+}
+
+func cancelAllAfterMessage(timeout time.Duration) string {
+	return fmt.Sprintf(
+		`{"op": "cancelAllAfter", "args": %d}`, int64(timeout/time.Millisecond),
+	)
+}
+
+// DeadMansSwitch - keeps cancelAllAfter armed, so orders are pulled by
+// exchange when process stops refreshing it. It can be started only once.
+// Every call is limited to half of interval, so slow refresh is reported
+// before next one is due
+type DeadMansSwitch struct {
+	target   Canceller
+	interval time.Duration
+	timeout  time.Duration
+
+	errors chan error
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+}
+
+// NewDeadMansSwitch - refreshes cancelAllAfter(timeout) of target every
+// interval, interval has to be shorter than timeout
+func NewDeadMansSwitch(target Canceller, interval, timeout time.Duration) (*DeadMansSwitch, error) {
+	if interval <= 0 || interval >= timeout {
+		return nil, ErrSwitchInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &DeadMansSwitch{
+		target:   target,
+		interval: interval,
+		timeout:  timeout,
+		errors:   make(chan error, 16),
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Start - arms switch and keeps refreshing it in background
+func (d *DeadMansSwitch) Start() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return ErrSwitchStopped
+	}
+	if d.started {
+		return ErrSwitchStarted
+	}
+
+	if err := d.call(context.Background(), d.timeout); err != nil {
+		return err
+	}
+
+	d.started = true
+	d.done = make(chan struct{})
+	go d.run()
+	return nil
+}
+
+// Stop - stops refreshing and disarms switch, orders stay open. Refresh in
+// progress is abandoned, switch is disarmed even if it does not finish in
+// time. Switch which was not started is not disarmed, calling Stop again
+// does nothing
+func (d *DeadMansSwitch) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return nil
+	}
+	d.stopped = true
+	d.cancel()
+
+	if !d.started {
+		return nil
+	}
+
+	select {
+	case <-d.done:
+	case <-time.After(d.interval / 2):
+		log.Warn("Dead man's switch refresh did not stop, disarming anyway")
+	}
+	return d.call(context.Background(), 0)
+}
+
+// Errors - failed refreshes, dropped if nobody reads them
+func (d *DeadMansSwitch) Errors() <-chan error {
+	return d.errors
+}
+
+func (d *DeadMansSwitch) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+			err := d.call(d.ctx, d.timeout)
+			if err != nil && d.ctx.Err() == nil {
+				log.Warnf("Dead man's switch refresh failed: %v", err)
+
+				select {
+				case d.errors <- err:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// call - calls target limited to half of interval
+func (d *DeadMansSwitch) call(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, d.interval/2)
+	defer cancel()
+
+	return d.target.CancelAllAfterContext(ctx, timeout)
+}
@@ -0,0 +1,178 @@
+package bitmex_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+type fakeCanceller struct {
+	sync.Mutex
+	calls []time.Duration
+	err   error
+	hang  bool
+}
+
+func (f *fakeCanceller) CancelAllAfterContext(ctx context.Context, timeout time.Duration) error {
+	f.Lock()
+	f.calls = append(f.calls, timeout)
+	err, hang := f.err, f.hang
+	f.Unlock()
+
+	if hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return err
+}
+
+func (f *fakeCanceller) Calls() []time.Duration {
+	f.Lock()
+	defer f.Unlock()
+	return append([]time.Duration(nil), f.calls...)
+}
+
+func (f *fakeCanceller) Fail(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.err = err
+}
+
+func (f *fakeCanceller) Hang() {
+	f.Lock()
+	defer f.Unlock()
+	f.hang = true
+}
+
+var _ = Describe("DeadMansSwitch", func() {
+	It("Should refresh and disarm on stop", func() {
+		target := &fakeCanceller{}
+		d, err := bitmex.NewDeadMansSwitch(target, 10*time.Millisecond, time.Minute)
+		Expect(err).To(Succeed())
+
+		Expect(d.Start()).To(Succeed())
+		Eventually(func() int { return len(target.Calls()) }).Should(BeNumerically(">=", 3))
+
+		Expect(d.Stop()).To(Succeed())
+		calls := target.Calls()
+		Expect(calls[0]).To(Equal(time.Minute))
+		Expect(calls[len(calls)-1]).To(BeZero())
+
+		time.Sleep(30 * time.Millisecond)
+		Expect(target.Calls()).To(HaveLen(len(calls)))
+	})
+
+	It("Should report refresh failures", func() {
+		target := &fakeCanceller{}
+		d, err := bitmex.NewDeadMansSwitch(target, 10*time.Millisecond, time.Minute)
+		Expect(err).To(Succeed())
+
+		Expect(d.Start()).To(Succeed())
+		defer d.Stop()
+
+		target.Fail(errors.New("overloaded"))
+		Eventually(d.Errors()).Should(Receive(MatchError("overloaded")))
+	})
+
+	It("Should limit refresh to half of interval", func() {
+		target := &fakeCanceller{}
+		d, err := bitmex.NewDeadMansSwitch(target, 100*time.Millisecond, time.Minute)
+		Expect(err).To(Succeed())
+
+		Expect(d.Start()).To(Succeed())
+		target.Hang()
+
+		start := time.Now()
+		Eventually(d.Errors()).Should(Receive(Equal(context.DeadlineExceeded)))
+		Expect(time.Since(start)).To(BeNumerically("<", 300*time.Millisecond))
+
+		// Hanging refresh is abandoned, disarm is sent and limited as well
+		start = time.Now()
+		Expect(d.Stop()).To(Equal(context.DeadlineExceeded))
+		Expect(time.Since(start)).To(BeNumerically("<", 150*time.Millisecond))
+
+		calls := target.Calls()
+		Expect(calls[len(calls)-1]).To(BeZero())
+	})
+
+	It("Should reject interval not shorter than timeout", func() {
+		_, err := bitmex.NewDeadMansSwitch(&fakeCanceller{}, time.Minute, time.Minute)
+		Expect(err).To(Equal(bitmex.ErrSwitchInterval))
+
+		_, err = bitmex.NewDeadMansSwitch(&fakeCanceller{}, 0, time.Minute)
+		Expect(err).To(Equal(bitmex.ErrSwitchInterval))
+	})
+
+	It("Should start only once", func() {
+		target := &fakeCanceller{}
+		d, err := bitmex.NewDeadMansSwitch(target, time.Hour, 2*time.Hour)
+		Expect(err).To(Succeed())
+
+		Expect(d.Start()).To(Succeed())
+		Expect(d.Start()).To(Equal(bitmex.ErrSwitchStarted))
+		Expect(target.Calls()).To(HaveLen(1))
+
+		Expect(d.Stop()).To(Succeed())
+		Expect(d.Stop()).To(Succeed())
+		Expect(d.Start()).To(Equal(bitmex.ErrSwitchStopped))
+		Expect(target.Calls()).To(Equal([]time.Duration{2 * time.Hour, 0}))
+	})
+
+	It("Should not disarm switch which was not started", func() {
+		target := &fakeCanceller{}
+		d, err := bitmex.NewDeadMansSwitch(target, time.Hour, 2*time.Hour)
+		Expect(err).To(Succeed())
+
+		Expect(d.Stop()).To(Succeed())
+		Expect(target.Calls()).To(BeEmpty())
+		Expect(d.Start()).To(Equal(bitmex.ErrSwitchStopped))
+	})
+
+	Context("Websocket", func() {
+		var srv *bitmextest.Server
+		var ws *bitmex.WS
+		var ctx context.Context
+		var cancel context.CancelFunc
+
+		BeforeEach(func() {
+			srv = bitmextest.NewServer("key", "secret")
+			ws = bitmex.NewWS(bitmex.WithDialer(srv.Dialer()), bitmex.WithHeartbeat(0, 0))
+			Expect(ws.Connect()).To(Succeed())
+			ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		})
+
+		AfterEach(func() {
+			cancel()
+			ws.Disconnect()
+			srv.Close()
+		})
+
+		It("Should report rejected request to Errors", func() {
+			Expect(ws.CancelAllAfter(time.Minute)).To(Succeed())
+			Eventually(ws.Errors()).Should(Receive(MatchError(ContainSubstring("Not authorized."))))
+		})
+
+		It("Should refresh switch when authenticated", func() {
+			d, err := bitmex.NewDeadMansSwitch(ws, 10*time.Millisecond, time.Minute)
+			Expect(err).To(Succeed())
+			Expect(d.Start()).To(MatchError(ContainSubstring("Not authorized.")))
+
+			Expect(ws.AuthContext(ctx, "key", "secret")).To(Succeed())
+
+			d, err = bitmex.NewDeadMansSwitch(ws, 10*time.Millisecond, time.Minute)
+			Expect(err).To(Succeed())
+			Expect(d.Start()).To(Succeed())
+			Consistently(d.Errors(), 50*time.Millisecond).ShouldNot(Receive())
+			Expect(d.Stop()).To(Succeed())
+
+			Expect(ws.CancelAllAfter(time.Minute)).To(Succeed())
+			Consistently(ws.Errors(), 50*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+})
@@ -0,0 +1,101 @@
+package bitmex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit - request budget reported by BitMEX in response headers
+type RateLimit struct {
+	Limit      int
+	Remaining  int
+	Reset      time.Time
+	RetryAfter time.Duration
+}
+
+func parseRateLimit(h http.Header) RateLimit {
+	var rl RateLimit
+
+	rl.Limit, _ = strconv.Atoi(h.Get("x-ratelimit-limit"))
+	rl.Remaining, _ = strconv.Atoi(h.Get("x-ratelimit-remaining"))
+
+	if reset, err := strconv.ParseInt(h.Get("x-ratelimit-reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+
+	if retry, err := strconv.Atoi(h.Get("retry-after")); err == nil {
+		rl.RetryAfter = time.Duration(retry) * time.Second
+	}
+
+	return rl
+}
+
+// APIError - error returned by BitMEX REST API
+type APIError struct {
+	StatusCode int
+	Name       string
+	Message    string
+	Method     string
+	Path       string
+	RateLimit  RateLimit
+}
+
+type apiErrorBody struct {
+	Error struct {
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newAPIError(req *http.Request, resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     req.Method,
+		Path:       req.URL.RequestURI(),
+		RateLimit:  parseRateLimit(resp.Header),
+	}
+
+	var parsed apiErrorBody
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error.Message != "" {
+		e.Name = parsed.Error.Name
+		e.Message = parsed.Error.Message
+	} else {
+		e.Name = http.StatusText(resp.StatusCode)
+		e.Message = strings.TrimSpace(string(body))
+	}
+
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf(
+		"bitmex: %s %s: %d %s: %s",
+		e.Method, e.Path, e.StatusCode, e.Name, e.Message,
+	)
+}
+
+// IsRateLimited - request was rejected because rate limit is exhausted
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsOverloaded - BitMEX rejected request with 503 "system overloaded",
+// such request is guaranteed not to be executed
+func (e *APIError) IsOverloaded() bool {
+	return e.StatusCode == http.StatusServiceUnavailable
+}
+
+// IsInsufficientBalance - order was rejected because of lack of margin
+func (e *APIError) IsInsufficientBalance() bool {
+	return strings.Contains(strings.ToLower(e.Message), "insufficient available balance")
+}
+
+// IsDuplicateClOrdID - order with the same ClOrdID was already accepted
+func (e *APIError) IsDuplicateClOrdID() bool {
+	return e.StatusCode == http.StatusBadRequest &&
+		strings.Contains(strings.ToLower(e.Message), "duplicate clordid")
+}
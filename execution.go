@@ -0,0 +1,66 @@
+package bitmex
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Liquidity indicators
+const (
+	AddedLiquidity   = "AddedLiquidity"
+	RemovedLiquidity = "RemovedLiquidity"
+)
+
+// Execution type - fill, order state change or funding of an account
+type Execution struct {
+	ExecID                string    `json:"execID"`
+	OrderID               uuid.UUID `json:"orderID"`
+	ClOrdID               string    `json:"clOrdID"`
+	ClOrdLinkID           string    `json:"clOrdLinkID"`
+	Account               int64     `json:"account"`
+	Symbol                Contract  `json:"symbol"`
+	Side                  string    `json:"side"`
+	LastQty               float64   `json:"lastQty"`
+	LastPx                float64   `json:"lastPx"`
+	UnderlyingLastPx      float64   `json:"underlyingLastPx"`
+	LastMkt               string    `json:"lastMkt"`
+	LastLiquidityInd      string    `json:"lastLiquidityInd"`
+	OrderQty              float64   `json:"orderQty"`
+	Price                 float64   `json:"price"`
+	DisplayQty            float64   `json:"displayQty"`
+	StopPx                float64   `json:"stopPx"`
+	PegOffsetValue        float64   `json:"pegOffsetValue"`
+	PegPriceType          string    `json:"pegPriceType"`
+	Currency              string    `json:"currency"`
+	SettlCurrency         string    `json:"settlCurrency"`
+	ExecType              string    `json:"execType"`
+	OrdType               string    `json:"ordType"`
+	TimeInForce           string    `json:"timeInForce"`
+	ExecInst              string    `json:"execInst"`
+	ContingencyType       string    `json:"contingencyType"`
+	ExDestination         string    `json:"exDestination"`
+	OrdStatus             string    `json:"ordStatus"`
+	Triggered             string    `json:"triggered"`
+	WorkingIndicator      bool      `json:"workingIndicator"`
+	OrdRejReason          string    `json:"ordRejReason"`
+	LeavesQty             float64   `json:"leavesQty"`
+	CumQty                float64   `json:"cumQty"`
+	AvgPx                 float64   `json:"avgPx"`
+	Commission            float64   `json:"commission"`
+	TradePublishIndicator string    `json:"tradePublishIndicator"`
+	MultiLegReportingType string    `json:"multiLegReportingType"`
+	Text                  string    `json:"text"`
+	TrdMatchID            string    `json:"trdMatchID"`
+	ExecCost              Satoshi   `json:"execCost"`
+	ExecComm              Satoshi   `json:"execComm"`
+	HomeNotional          float64   `json:"homeNotional"`
+	ForeignNotional       float64   `json:"foreignNotional"`
+	TransactTime          time.Time `json:"transactTime"`
+	Timestamp             time.Time `json:"timestamp"`
+}
+
+// IsMaker - execution added liquidity, negative ExecComm is a rebate
+func (e Execution) IsMaker() bool {
+	return e.LastLiquidityInd == AddedLiquidity
+}
@@ -0,0 +1,272 @@
+package bitmex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownInstrument - order symbol is missing in instrument registry
+var ErrUnknownInstrument = errors.New("bitmex: unknown instrument")
+
+// Instrument - contract specification and market state
+type Instrument struct {
+	Symbol           Contract  `json:"symbol"`
+	RootSymbol       string    `json:"rootSymbol"`
+	State            string    `json:"state"`
+	Typ              string    `json:"typ"`
+	Listing          time.Time `json:"listing"`
+	Front            time.Time `json:"front"`
+	Expiry           time.Time `json:"expiry"`
+	Settle           time.Time `json:"settle"`
+	PositionCurrency string    `json:"positionCurrency"`
+	Underlying       string    `json:"underlying"`
+	QuoteCurrency    string    `json:"quoteCurrency"`
+	SettlCurrency    string    `json:"settlCurrency"`
+	ReferenceSymbol  string    `json:"referenceSymbol"`
+
+	MaxOrderQty float64 `json:"maxOrderQty"`
+	MaxPrice    float64 `json:"maxPrice"`
+	LotSize     float64 `json:"lotSize"`
+	TickSize    float64 `json:"tickSize"`
+	Multiplier  float64 `json:"multiplier"`
+	IsQuanto    bool    `json:"isQuanto"`
+	IsInverse   bool    `json:"isInverse"`
+
+	InitMargin    float64 `json:"initMargin"`
+	MaintMargin   float64 `json:"maintMargin"`
+	RiskLimit     int64   `json:"riskLimit"`
+	RiskStep      int64   `json:"riskStep"`
+	MakerFee      float64 `json:"makerFee"`
+	TakerFee      float64 `json:"takerFee"`
+	SettlementFee float64 `json:"settlementFee"`
+
+	FundingTimestamp      time.Time `json:"fundingTimestamp"`
+	FundingInterval       time.Time `json:"fundingInterval"`
+	FundingRate           float64   `json:"fundingRate"`
+	IndicativeFundingRate float64   `json:"indicativeFundingRate"`
+
+	PrevClosePrice        float64 `json:"prevClosePrice"`
+	LimitDownPrice        float64 `json:"limitDownPrice"`
+	LimitUpPrice          float64 `json:"limitUpPrice"`
+	TotalVolume           int64   `json:"totalVolume"`
+	Volume                int64   `json:"volume"`
+	Volume24h             int64   `json:"volume24h"`
+	OpenInterest          int64   `json:"openInterest"`
+	OpenValue             int64   `json:"openValue"`
+	FairMethod            string  `json:"fairMethod"`
+	FairBasisRate         float64 `json:"fairBasisRate"`
+	FairBasis             float64 `json:"fairBasis"`
+	FairPrice             float64 `json:"fairPrice"`
+	MarkMethod            string  `json:"markMethod"`
+	MarkPrice             float64 `json:"markPrice"`
+	IndicativeSettlePrice float64 `json:"indicativeSettlePrice"`
+	LastPrice             float64 `json:"lastPrice"`
+	LastTickDirection     string  `json:"lastTickDirection"`
+	LastChangePcnt        float64 `json:"lastChangePcnt"`
+	BidPrice              float64 `json:"bidPrice"`
+	MidPrice              float64 `json:"midPrice"`
+	AskPrice              float64 `json:"askPrice"`
+	ImpactBidPrice        float64 `json:"impactBidPrice"`
+	ImpactMidPrice        float64 `json:"impactMidPrice"`
+	ImpactAskPrice        float64 `json:"impactAskPrice"`
+	HighPrice             float64 `json:"highPrice"`
+	LowPrice              float64 `json:"lowPrice"`
+	Vwap                  float64 `json:"vwap"`
+	Turnover24h           int64   `json:"turnover24h"`
+	HomeNotional24h       float64 `json:"homeNotional24h"`
+	ForeignNotional24h    float64 `json:"foreignNotional24h"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Instruments - registry of instrument specifications, loaded by Load and
+// kept fresh by Watch, safe for concurrent use
+type Instruments struct {
+	mu sync.RWMutex
+	m  map[Contract]*Instrument
+}
+
+// NewInstruments - creates empty registry
+func NewInstruments() *Instruments {
+	return &Instruments{m: make(map[Contract]*Instrument)}
+}
+
+// Load - replaces registry content with active instruments
+func (in *Instruments) Load(r *REST) error {
+	return in.LoadContext(context.Background(), r)
+}
+
+// LoadContext - Load with context
+func (in *Instruments) LoadContext(ctx context.Context, r *REST) error {
+	instruments, err := r.ActiveInstrumentsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.m = make(map[Contract]*Instrument, len(instruments))
+	for i := range instruments {
+		in.m[instruments[i].Symbol] = &instruments[i]
+	}
+	return nil
+}
+
+// Watch - keeps registry updated from websocket instrument table
+func (in *Instruments) Watch(ws *WS) error {
+	ws.Lock()
+	ws.instruments = in
+	ws.Unlock()
+
+	return ws.subscribe("instrument")
+}
+
+// Get - instrument by symbol
+func (in *Instruments) Get(symbol Contract) (Instrument, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	one, ok := in.m[symbol]
+	if !ok {
+		return Instrument{}, false
+	}
+	return *one, true
+}
+
+// All - every known instrument sorted by symbol
+func (in *Instruments) All() []Instrument {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	res := make([]Instrument, 0, len(in.m))
+	for _, one := range in.m {
+		res = append(res, *one)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Symbol < res[j].Symbol })
+	return res
+}
+
+// apply - applies instrument table message, updates carry only changed
+// fields and are merged into known rows; returns merged rows. Partial of
+// per-contract topic replaces only its contract
+func (in *Instruments) apply(msg wsData) []Instrument {
+	var rows []json.RawMessage
+	json.Unmarshal(msg.Data, &rows)
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	action := msg.Action
+	if action == "partial" {
+		if symbol, ok := msg.filterSymbol(); ok {
+			delete(in.m, symbol)
+		} else {
+			in.m = make(map[Contract]*Instrument, len(rows))
+		}
+	}
+
+	var res []Instrument
+
+	for _, row := range rows {
+		var key struct {
+			Symbol Contract `json:"symbol"`
+		}
+		if err := json.Unmarshal(row, &key); err != nil || key.Symbol == "" {
+			continue
+		}
+
+		if action == "delete" {
+			delete(in.m, key.Symbol)
+			continue
+		}
+
+		one, ok := in.m[key.Symbol]
+		if !ok {
+			one = &Instrument{}
+			in.m[key.Symbol] = one
+		}
+		json.Unmarshal(row, one)
+		res = append(res, *one)
+	}
+
+	return res
+}
+
+// Validate - checks order price, stop price and quantity against tick
+// size, lot size and maximum order quantity of its instrument
+func (in *Instruments) Validate(o *Order) error {
+	one, ok := in.Get(o.Symbol)
+	if !ok {
+		return ErrUnknownInstrument
+	}
+
+	if !isMultiple(o.Price, one.TickSize) {
+		return fmt.Errorf("bitmex: price %v of %s is not multiple of tick size %v", o.Price, o.Symbol, one.TickSize)
+	}
+
+	if !isMultiple(o.StopPx, one.TickSize) {
+		return fmt.Errorf("bitmex: stop price %v of %s is not multiple of tick size %v", o.StopPx, o.Symbol, one.TickSize)
+	}
+
+	if !isMultiple(o.OrderQty, one.LotSize) {
+		return fmt.Errorf("bitmex: quantity %v of %s is not multiple of lot size %v", o.OrderQty, o.Symbol, one.LotSize)
+	}
+
+	if one.MaxOrderQty > 0 && math.Abs(o.OrderQty) > one.MaxOrderQty {
+		return fmt.Errorf("bitmex: quantity %v of %s exceeds maximum %v", o.OrderQty, o.Symbol, one.MaxOrderQty)
+	}
+
+	return nil
+}
+
+// Round - rounds order price and stop price to nearest tick and quantity
+// down to lot size, then validates it
+func (in *Instruments) Round(o *Order) error {
+	one, ok := in.Get(o.Symbol)
+	if !ok {
+		return ErrUnknownInstrument
+	}
+
+	o.Price = roundStep(o.Price, one.TickSize, math.Floor(o.Price/one.TickSize+0.5))
+	o.StopPx = roundStep(o.StopPx, one.TickSize, math.Floor(o.StopPx/one.TickSize+0.5))
+	o.OrderQty = roundStep(o.OrderQty, one.LotSize, math.Trunc(o.OrderQty/one.LotSize))
+
+	if o.OrderQty == 0 && o.ExecInst != Close {
+		return fmt.Errorf("bitmex: quantity of %s is below lot size %v", o.Symbol, one.LotSize)
+	}
+
+	return in.Validate(o)
+}
+
+func isMultiple(v, step float64) bool {
+	if step <= 0 || v == 0 {
+		return true
+	}
+	steps := v / step
+	return math.Abs(steps-math.Floor(steps+0.5)) < 1e-6
+}
+
+// roundStep - steps*step without float noise, e.g. 0.1+0.2
+func roundStep(v, step, steps float64) float64 {
+	if step <= 0 || v == 0 {
+		return v
+	}
+
+	decimals := 0
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		decimals = len(s) - i - 1
+	}
+
+	res, _ := strconv.ParseFloat(strconv.FormatFloat(steps*step, 'f', decimals, 64), 64)
+	return res
+}
@@ -0,0 +1,81 @@
+package bitmex
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instruments", func() {
+	var in *Instruments
+
+	BeforeEach(func() {
+		in = NewInstruments()
+		in.apply(wsData{Action: "partial", Data: []byte(`[
+			{"symbol":"XBTUSD","tickSize":0.5,"lotSize":1,"maxOrderQty":10000000,"isInverse":true,"markPrice":6500},
+			{"symbol":"ETHXBT","tickSize":0.00001,"lotSize":1,"maxOrderQty":100000000}
+		]`)})
+	})
+
+	It("Should merge updates into known rows", func() {
+		rows := in.apply(wsData{Action: "update", Data: []byte(`[{"symbol":"XBTUSD","markPrice":6510.25}]`)})
+		Expect(rows).To(HaveLen(1))
+
+		one, ok := in.Get(XBTUSD)
+		Expect(ok).To(BeTrue())
+		Expect(one.MarkPrice).To(Equal(6510.25))
+		Expect(one.TickSize).To(Equal(0.5))
+		Expect(one.IsInverse).To(BeTrue())
+
+		in.apply(wsData{Action: "delete", Data: []byte(`[{"symbol":"ETHXBT"}]`)})
+		Expect(in.All()).To(HaveLen(1))
+	})
+
+	It("Should validate orders", func() {
+		o := NewOrder(XBTUSD)
+		o.OrderQty = 100
+		o.Price = 6500.5
+		Expect(in.Validate(o)).To(Succeed())
+
+		o.Price = 6500.3
+		Expect(in.Validate(o)).NotTo(Succeed())
+
+		o.Price = 6500
+		o.OrderQty = 20000000
+		Expect(in.Validate(o)).NotTo(Succeed())
+
+		Expect(in.Validate(NewOrder("XBTZ99"))).To(Equal(ErrUnknownInstrument))
+	})
+
+	It("Should round orders", func() {
+		o := NewOrder("ETHXBT")
+		o.OrderQty = 10.7
+		o.Price = 0.0341234
+
+		Expect(in.Round(o)).To(Succeed())
+		Expect(o.Price).To(Equal(0.03412))
+		Expect(o.OrderQty).To(Equal(10.0))
+
+		o.OrderQty = 0.4
+		Expect(in.Round(o)).NotTo(Succeed())
+	})
+
+	It("Should keep contracts of other per-contract partials", func() {
+		in = NewInstruments()
+		in.apply(wsData{Action: "partial", Filter: map[string]interface{}{"symbol": "XBTUSD"}, Data: []byte(`[
+			{"symbol":"XBTUSD","tickSize":0.5,"lotSize":1}
+		]`)})
+		in.apply(wsData{Action: "partial", Filter: map[string]interface{}{"symbol": "ETHUSD"}, Data: []byte(`[
+			{"symbol":"ETHUSD","tickSize":0.05,"lotSize":1}
+		]`)})
+		in.apply(wsData{Action: "update", Data: []byte(`[{"symbol":"XBTUSD","markPrice":6500}]`)})
+
+		xbt, ok := in.Get(XBTUSD)
+		Expect(ok).To(BeTrue())
+		Expect(xbt.TickSize).To(Equal(0.5))
+		Expect(xbt.MarkPrice).To(Equal(6500.0))
+
+		eth, ok := in.Get("ETHUSD")
+		Expect(ok).To(BeTrue())
+		Expect(eth.TickSize).To(Equal(0.05))
+	})
+})
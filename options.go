@@ -0,0 +1,186 @@
+package bitmex
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	testnetEndpoint = "https://testnet.bitmex.com"
+	testnetWSURL    = "wss://testnet.bitmex.com/realtime"
+
+	// validity of api-expires signatures
+	defaultExpires = 10 * time.Second
+)
+
+type config struct {
+	restURL string
+	wsURL   string
+
+	reconnectMin, reconnectMax time.Duration
+	pingInterval, pongTimeout  time.Duration
+
+	instruments *Instruments
+	roundOrders bool
+
+	rateReserve int
+	rateQueue   bool
+
+	retry *RetryPolicy
+
+	expires time.Duration
+
+	httpClient *http.Client
+
+	recorder *Recorder
+	dialer   Dialer
+}
+
+// Option - configures REST and WS objects
+type Option func(*config)
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		restURL:      endpoint,
+		wsURL:        wsURL,
+		reconnectMin: time.Second,
+		reconnectMax: 30 * time.Second,
+		pingInterval: 5 * time.Second,
+		pongTimeout:  5 * time.Second,
+		rateReserve:  5,
+		rateQueue:    true,
+		expires:      defaultExpires,
+		dialer:       &XNetDialer{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Production - use production BitMEX servers (default)
+func Production() Option {
+	return func(c *config) {
+		c.restURL = endpoint
+		c.wsURL = wsURL
+	}
+}
+
+// Testnet - use testnet.bitmex.com servers
+func Testnet() Option {
+	return func(c *config) {
+		c.restURL = testnetEndpoint
+		c.wsURL = testnetWSURL
+	}
+}
+
+// WithBaseURL - use arbitrary server, e.g. "http://127.0.0.1:8080".
+// REST requests go to base + "/api/v1", websocket connects to
+// base + "/realtime" with http(s) scheme replaced by ws(s)
+func WithBaseURL(base string) Option {
+	return func(c *config) {
+		base = strings.TrimRight(base, "/")
+		c.restURL = base
+
+		switch {
+		case strings.HasPrefix(base, "https://"):
+			c.wsURL = "wss://" + strings.TrimPrefix(base, "https://") + "/realtime"
+		case strings.HasPrefix(base, "http://"):
+			c.wsURL = "ws://" + strings.TrimPrefix(base, "http://") + "/realtime"
+		default:
+			c.wsURL = base + "/realtime"
+		}
+	}
+}
+
+// WithWSURL - overrides websocket URL only
+func WithWSURL(url string) Option {
+	return func(c *config) {
+		c.wsURL = url
+	}
+}
+
+// WithReconnect - WS reconnection backoff, delay doubles from min up to max
+func WithReconnect(min, max time.Duration) Option {
+	return func(c *config) {
+		c.reconnectMin = min
+		c.reconnectMax = max
+	}
+}
+
+// WithHeartbeat - WS sends ping after idle period without incoming data and
+// reconnects if pong is not received within timeout, zero idle disables it
+func WithHeartbeat(idle, timeout time.Duration) Option {
+	return func(c *config) {
+		c.pingInterval = idle
+		c.pongTimeout = timeout
+	}
+}
+
+// WithOrderValidation - REST checks orders against instrument registry before
+// sending them, with round price and quantity are rounded instead of rejected
+func WithOrderValidation(instruments *Instruments, round bool) Option {
+	return func(c *config) {
+		c.instruments = instruments
+		c.roundOrders = round
+	}
+}
+
+// WithRateLimit - REST keeps last reserve requests of rate limit budget for
+// cancellations and either waits for budget (queue) or fails with
+// ErrRateLimited when it is exhausted. Defaults to reserve 5 with queueing
+func WithRateLimit(reserve int, queue bool) Option {
+	return func(c *config) {
+		c.rateReserve = reserve
+		c.rateQueue = queue
+	}
+}
+
+// WithRetry - enables retry of OrderSend, e.g. WithRetry(DefaultRetryPolicy)
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = &policy
+	}
+}
+
+// WithExpires - requests are signed with api-expires set window from now,
+// server rejects them once it passes. Defaults to 10s
+func WithExpires(window time.Duration) Option {
+	return func(c *config) {
+		c.expires = window
+	}
+}
+
+// WithLegacyNonce - sign with increasing api-nonce instead of api-expires.
+// Nonce has to grow across all processes sharing key, so use only with
+// single client per key
+func WithLegacyNonce() Option {
+	return func(c *config) {
+		c.expires = 0
+	}
+}
+
+// WithHTTPClient - REST uses client instead of default one with tuned
+// connection pool, e.g. for proxies, tracing or custom timeouts
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithRecorder - WS writes every received frame to recorder, see Replay
+func WithRecorder(rec *Recorder) Option {
+	return func(c *config) {
+		c.recorder = rec
+	}
+}
+
+// WithDialer - WS connects through dialer instead of golang.org/x/net/websocket
+func WithDialer(d Dialer) Option {
+	return func(c *config) {
+		c.dialer = d
+	}
+}
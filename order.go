@@ -82,6 +82,9 @@ type Order struct {
 	TransactTime          time.Time `json:"transactTime,omitempty"`
 	Triggered             string    `json:"triggered,omitempty"`
 	WorkingIndicator      bool      `json:"workingIndicator,omitempty"`
+
+	// Error - reason why order from bulk cancellation was not cancelled
+	Error string `json:"error,omitempty"`
 }
 
 //NewOrder constructor
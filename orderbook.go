@@ -0,0 +1,233 @@
+package bitmex
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Order book tables
+const (
+	OrderBookL2   = "orderBookL2"
+	OrderBookL225 = "orderBookL2_25"
+)
+
+var errUnknownLevel = errors.New("order book: update for unknown id")
+
+// OrderBookLevel - single price level of L2 order book
+type OrderBookLevel struct {
+	Symbol Contract `json:"symbol"`
+	ID     int64    `json:"id"`
+	Side   string   `json:"side"`
+	Size   float64  `json:"size"`
+	Price  float64  `json:"price"`
+}
+
+// OrderBook - local copy of L2 order book of one contract, safe for
+// concurrent reading while WS applies updates
+type OrderBook struct {
+	Symbol Contract
+
+	mu     sync.RWMutex
+	synced bool
+	levels map[int64]*OrderBookLevel
+	bids   []*OrderBookLevel // highest price first
+	asks   []*OrderBookLevel // lowest price first
+}
+
+// NewOrderBook - creates empty order book
+func NewOrderBook(symbol Contract) *OrderBook {
+	return &OrderBook{
+		Symbol: symbol,
+		levels: make(map[int64]*OrderBookLevel),
+	}
+}
+
+// Synced - book received partial and is consistent with exchange
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// BestBid - highest bid level
+func (b *OrderBook) BestBid() (OrderBookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.bids) == 0 {
+		return OrderBookLevel{}, false
+	}
+	return *b.bids[0], true
+}
+
+// BestAsk - lowest ask level
+func (b *OrderBook) BestAsk() (OrderBookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.asks) == 0 {
+		return OrderBookLevel{}, false
+	}
+	return *b.asks[0], true
+}
+
+// Bids - up to depth best bid levels, depth <= 0 returns all of them
+func (b *OrderBook) Bids(depth int) []OrderBookLevel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return copyLevels(b.bids, depth)
+}
+
+// Asks - up to depth best ask levels, depth <= 0 returns all of them
+func (b *OrderBook) Asks(depth int) []OrderBookLevel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return copyLevels(b.asks, depth)
+}
+
+// Level - looks up price level of the side ("Buy" or "Sell") by price
+func (b *OrderBook) Level(side string, price float64) (OrderBookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	levels := b.side(side)
+	i := searchLevel(levels, side, price)
+	if i < len(levels) && levels[i].Price == price {
+		return *levels[i], true
+	}
+	return OrderBookLevel{}, false
+}
+
+func copyLevels(levels []*OrderBookLevel, depth int) []OrderBookLevel {
+	if depth <= 0 || depth > len(levels) {
+		depth = len(levels)
+	}
+
+	res := make([]OrderBookLevel, depth)
+	for i := range res {
+		res[i] = *levels[i]
+	}
+	return res
+}
+
+func (b *OrderBook) side(side string) []*OrderBookLevel {
+	if side == "Buy" {
+		return b.bids
+	}
+	return b.asks
+}
+
+func (b *OrderBook) setSide(side string, levels []*OrderBookLevel) {
+	if side == "Buy" {
+		b.bids = levels
+	} else {
+		b.asks = levels
+	}
+}
+
+// searchLevel - index of the first level which is not better than price
+func searchLevel(levels []*OrderBookLevel, side string, price float64) int {
+	if side == "Buy" {
+		return sort.Search(len(levels), func(i int) bool { return levels[i].Price <= price })
+	}
+	return sort.Search(len(levels), func(i int) bool { return levels[i].Price >= price })
+}
+
+func (b *OrderBook) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.synced = false
+	b.levels = make(map[int64]*OrderBookLevel)
+	b.bids, b.asks = nil, nil
+}
+
+// apply - applies table action, errUnknownLevel means book is out of sync
+// and has to be resubscribed; until next partial other actions are ignored
+func (b *OrderBook) apply(action string, rows []OrderBookLevel) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if action == "partial" {
+		b.synced = true
+		b.levels = make(map[int64]*OrderBookLevel, len(rows))
+		b.bids, b.asks = nil, nil
+	} else if !b.synced {
+		return nil
+	}
+
+	for _, row := range rows {
+		var err error
+
+		switch action {
+		case "partial", "insert":
+			b.insert(row)
+		case "update":
+			err = b.update(row)
+		case "delete":
+			err = b.remove(row)
+		}
+
+		if err != nil {
+			b.synced = false
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *OrderBook) insert(row OrderBookLevel) {
+	if _, ok := b.levels[row.ID]; ok {
+		b.remove(row)
+	}
+
+	level := row
+	b.levels[row.ID] = &level
+
+	levels := b.side(row.Side)
+	i := searchLevel(levels, row.Side, row.Price)
+	levels = append(levels, nil)
+	copy(levels[i+1:], levels[i:])
+	levels[i] = &level
+	b.setSide(row.Side, levels)
+}
+
+func (b *OrderBook) update(row OrderBookLevel) error {
+	level, ok := b.levels[row.ID]
+	if !ok {
+		return errUnknownLevel
+	}
+
+	if row.Side != "" && row.Side != level.Side {
+		// Level moved to other side of the book
+		moved := *level
+		moved.Side = row.Side
+		moved.Size = row.Size
+		b.remove(*level)
+		b.insert(moved)
+		return nil
+	}
+
+	level.Size = row.Size
+	return nil
+}
+
+func (b *OrderBook) remove(row OrderBookLevel) error {
+	level, ok := b.levels[row.ID]
+	if !ok {
+		return errUnknownLevel
+	}
+	delete(b.levels, row.ID)
+
+	levels := b.side(level.Side)
+	for i := searchLevel(levels, level.Side, level.Price); i < len(levels); i++ {
+		if levels[i] == level {
+			b.setSide(level.Side, append(levels[:i], levels[i+1:]...))
+			break
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package bitmex
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OrderBook", func() {
+	var book *OrderBook
+
+	BeforeEach(func() {
+		book = NewOrderBook(XBTUSD)
+		err := book.apply("partial", []OrderBookLevel{
+			{Symbol: XBTUSD, ID: 1, Side: "Sell", Size: 10, Price: 101},
+			{Symbol: XBTUSD, ID: 2, Side: "Sell", Size: 20, Price: 100.5},
+			{Symbol: XBTUSD, ID: 3, Side: "Buy", Size: 30, Price: 100},
+			{Symbol: XBTUSD, ID: 4, Side: "Buy", Size: 40, Price: 99},
+		})
+		Expect(err).To(Succeed())
+	})
+
+	It("Should keep sides sorted", func() {
+		bid, ok := book.BestBid()
+		Expect(ok).To(BeTrue())
+		Expect(bid.Price).To(Equal(100.0))
+
+		ask, ok := book.BestAsk()
+		Expect(ok).To(BeTrue())
+		Expect(ask.Price).To(Equal(100.5))
+
+		Expect(book.Asks(0)).To(HaveLen(2))
+		Expect(book.Bids(1)).To(Equal([]OrderBookLevel{
+			{Symbol: XBTUSD, ID: 3, Side: "Buy", Size: 30, Price: 100},
+		}))
+	})
+
+	It("Should apply insert, update and delete", func() {
+		Expect(book.apply("insert", []OrderBookLevel{
+			{Symbol: XBTUSD, ID: 5, Side: "Buy", Size: 5, Price: 100.25},
+		})).To(Succeed())
+		Expect(book.apply("update", []OrderBookLevel{
+			{Symbol: XBTUSD, ID: 4, Side: "Buy", Size: 45},
+		})).To(Succeed())
+		Expect(book.apply("delete", []OrderBookLevel{
+			{Symbol: XBTUSD, ID: 2, Side: "Sell"},
+		})).To(Succeed())
+
+		bid, _ := book.BestBid()
+		Expect(bid.ID).To(BeEquivalentTo(5))
+
+		ask, _ := book.BestAsk()
+		Expect(ask.Price).To(Equal(101.0))
+
+		level, ok := book.Level("Buy", 99)
+		Expect(ok).To(BeTrue())
+		Expect(level.Size).To(Equal(45.0))
+
+		_, ok = book.Level("Sell", 100.5)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Should detect unknown id", func() {
+		err := book.apply("update", []OrderBookLevel{
+			{Symbol: XBTUSD, ID: 42, Side: "Buy", Size: 1},
+		})
+		Expect(err).To(Equal(errUnknownLevel))
+		Expect(book.Synced()).To(BeFalse())
+
+		// Ignored until next partial
+		Expect(book.apply("delete", []OrderBookLevel{{Symbol: XBTUSD, ID: 1}})).To(Succeed())
+		Expect(book.Asks(0)).To(HaveLen(2))
+	})
+
+	It("Should sync book by empty partial", func() {
+		ws := NewWS()
+		Expect(ws.SubOrderBook(nil, OrderBookL2, []Contract{"XBTZ99"})).To(Succeed())
+		Expect(ws.OrderBook("XBTZ99").Synced()).To(BeFalse())
+
+		ws.dispatch(`{"table":"orderBookL2","action":"partial","keys":["symbol","id","side"],"filter":{"symbol":"XBTZ99"},"data":[]}`)
+		Expect(ws.OrderBook("XBTZ99").Synced()).To(BeTrue())
+
+		ws.dispatch(`{"table":"orderBookL2","action":"insert","data":[{"symbol":"XBTZ99","id":1,"side":"Buy","size":10,"price":6000}]}`)
+		bid, ok := ws.OrderBook("XBTZ99").BestBid()
+		Expect(ok).To(BeTrue())
+		Expect(bid.Price).To(Equal(6000.0))
+	})
+})
@@ -0,0 +1,80 @@
+package bitmex
+
+import "time"
+
+// Position type, shared by REST and websocket position table. Margin and
+// PnL amounts are in satoshis of settlement currency
+type Position struct {
+	Account       int64    `json:"account"`
+	Symbol        Contract `json:"symbol"`
+	Currency      string   `json:"currency"`
+	Underlying    string   `json:"underlying"`
+	QuoteCurrency string   `json:"quoteCurrency"`
+
+	Commission           float64 `json:"commission"`
+	InitMarginReq        float64 `json:"initMarginReq"`
+	MaintMarginReq       float64 `json:"maintMarginReq"`
+	RiskLimit            int64   `json:"riskLimit"`
+	Leverage             float64 `json:"leverage"`
+	CrossMargin          bool    `json:"crossMargin"`
+	DeleveragePercentile float64 `json:"deleveragePercentile"`
+
+	RebalancedPnl     int64 `json:"rebalancedPnl"`
+	PrevRealisedPnl   int64 `json:"prevRealisedPnl"`
+	PrevUnrealisedPnl int64 `json:"prevUnrealisedPnl"`
+
+	OpeningQty       int64 `json:"openingQty"`
+	OpenOrderBuyQty  int64 `json:"openOrderBuyQty"`
+	OpenOrderSellQty int64 `json:"openOrderSellQty"`
+	ExecBuyQty       int64 `json:"execBuyQty"`
+	ExecSellQty      int64 `json:"execSellQty"`
+	ExecQty          int64 `json:"execQty"`
+	CurrentQty       int64 `json:"currentQty"`
+	CurrentCost      int64 `json:"currentCost"`
+	CurrentComm      int64 `json:"currentComm"`
+	RealisedCost     int64 `json:"realisedCost"`
+	UnrealisedCost   int64 `json:"unrealisedCost"`
+	IsOpen           bool  `json:"isOpen"`
+
+	MarkPrice       float64 `json:"markPrice"`
+	MarkValue       int64   `json:"markValue"`
+	RiskValue       int64   `json:"riskValue"`
+	HomeNotional    float64 `json:"homeNotional"`
+	ForeignNotional float64 `json:"foreignNotional"`
+	PosState        string  `json:"posState"`
+
+	PosCost      int64 `json:"posCost"`
+	PosCross     int64 `json:"posCross"`
+	PosInit      int64 `json:"posInit"`
+	PosComm      int64 `json:"posComm"`
+	PosLoss      int64 `json:"posLoss"`
+	PosMargin    int64 `json:"posMargin"`
+	PosMaint     int64 `json:"posMaint"`
+	PosAllowance int64 `json:"posAllowance"`
+	InitMargin   int64 `json:"initMargin"`
+	MaintMargin  int64 `json:"maintMargin"`
+
+	RealisedGrossPnl   int64   `json:"realisedGrossPnl"`
+	RealisedPnl        int64   `json:"realisedPnl"`
+	UnrealisedGrossPnl int64   `json:"unrealisedGrossPnl"`
+	UnrealisedPnl      int64   `json:"unrealisedPnl"`
+	UnrealisedPnlPcnt  float64 `json:"unrealisedPnlPcnt"`
+	UnrealisedRoePcnt  float64 `json:"unrealisedRoePcnt"`
+
+	SimpleQty     float64 `json:"simpleQty"`
+	SimpleCost    float64 `json:"simpleCost"`
+	SimpleValue   float64 `json:"simpleValue"`
+	SimplePnl     float64 `json:"simplePnl"`
+	SimplePnlPcnt float64 `json:"simplePnlPcnt"`
+
+	AvgCostPrice     float64 `json:"avgCostPrice"`
+	AvgEntryPrice    float64 `json:"avgEntryPrice"`
+	BreakEvenPrice   float64 `json:"breakEvenPrice"`
+	MarginCallPrice  float64 `json:"marginCallPrice"`
+	LiquidationPrice float64 `json:"liquidationPrice"`
+	BankruptPrice    float64 `json:"bankruptPrice"`
+
+	LastPrice float64   `json:"lastPrice"`
+	LastValue int64     `json:"lastValue"`
+	Timestamp time.Time `json:"timestamp"`
+}
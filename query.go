@@ -0,0 +1,79 @@
+package bitmex
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Query - common parameters of GET requests
+type Query struct {
+	Symbol    Contract
+	Currency  string
+	Filter    map[string]interface{}
+	Columns   []string
+	Count     int
+	Start     int
+	Reverse   bool
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// Values encodes query into URL parameters, zero fields are omitted
+func (q *Query) Values() (url.Values, error) {
+	v := url.Values{}
+	if q == nil {
+		return v, nil
+	}
+
+	if q.Symbol != "" {
+		v.Set("symbol", string(q.Symbol))
+	}
+
+	if q.Currency != "" {
+		v.Set("currency", q.Currency)
+	}
+
+	if len(q.Filter) > 0 {
+		filter, err := json.Marshal(q.Filter)
+		if err != nil {
+			return nil, err
+		}
+		v.Set("filter", string(filter))
+	}
+
+	if len(q.Columns) > 0 {
+		columns, err := json.Marshal(q.Columns)
+		if err != nil {
+			return nil, err
+		}
+		v.Set("columns", string(columns))
+	}
+
+	if q.Count > 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+
+	if q.Start > 0 {
+		v.Set("start", strconv.Itoa(q.Start))
+	}
+
+	if q.Reverse {
+		v.Set("reverse", "true")
+	}
+
+	if !q.StartTime.IsZero() {
+		v.Set("startTime", formatTime(q.StartTime))
+	}
+
+	if !q.EndTime.IsZero() {
+		v.Set("endTime", formatTime(q.EndTime))
+	}
+
+	return v, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
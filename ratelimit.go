@@ -0,0 +1,157 @@
+package bitmex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited - request was not sent because rate limit budget is exhausted
+var ErrRateLimited = errors.New("bitmex: rate limit exhausted")
+
+// rateLimiter - token bucket refilled over a minute like BitMEX counter,
+// synchronized with budget reported in response headers. Last reserve
+// tokens are available only to cancellations
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	tokens  float64
+	updated time.Time
+	reset   time.Time
+	blocked time.Time
+	reserve float64
+	queue   bool
+}
+
+func newRateLimiter(limit, reserve int, queue bool) *rateLimiter {
+	return &rateLimiter{
+		limit:   float64(limit),
+		tokens:  float64(limit),
+		updated: time.Now(),
+		reserve: float64(reserve),
+		queue:   queue,
+	}
+}
+
+// refill - must be called locked
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.updated).Seconds() * l.limit / 60
+	if l.tokens > l.limit {
+		l.tokens = l.limit
+	}
+	l.updated = now
+}
+
+// take - reserves one request, returns how long to wait if none is available
+func (l *rateLimiter) take(cancel bool) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(l.blocked) {
+		return l.blocked.Sub(now)
+	}
+
+	l.refill(now)
+
+	threshold := l.reserve
+	if cancel {
+		threshold = 0
+	}
+
+	if l.tokens-1 >= threshold {
+		l.tokens--
+		return 0
+	}
+
+	missing := threshold + 1 - l.tokens
+	return time.Duration(missing * 60 / l.limit * float64(time.Second))
+}
+
+// wait - blocks until request can be sent or ctx is done, rejects request
+// in non queue mode
+func (l *rateLimiter) wait(ctx context.Context, cancel bool) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		delay := l.take(cancel)
+		if delay == 0 {
+			return nil
+		}
+		if !l.queue {
+			return ErrRateLimited
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext - sleeps for d unless ctx is done earlier
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update - adopts budget reported by server
+func (l *rateLimiter) update(status int, h http.Header) {
+	rl := parseRateLimit(h)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	if rl.Limit > 0 {
+		l.limit = float64(rl.Limit)
+		if remaining := float64(rl.Remaining); remaining < l.tokens {
+			l.tokens = remaining
+		}
+		l.reset = rl.Reset
+	}
+
+	if status == http.StatusTooManyRequests {
+		l.tokens = 0
+		retry := rl.RetryAfter
+		if retry == 0 {
+			retry = time.Second
+		}
+		l.blocked = now.Add(retry)
+	}
+}
+
+func (l *rateLimiter) state() RateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	rl := RateLimit{
+		Limit:     int(l.limit),
+		Remaining: int(l.tokens),
+		Reset:     l.reset,
+	}
+	if now.Before(l.blocked) {
+		rl.RetryAfter = l.blocked.Sub(now)
+	}
+	return rl
+}
+
+// RateLimit - current request budget as tracked by client, strategies can
+// scale quoting activity by Remaining
+func (r *REST) RateLimit() RateLimit {
+	return r.limiter.state()
+}
@@ -0,0 +1,44 @@
+package bitmex
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RateLimit", func() {
+	It("Should keep reserve for cancellations", func() {
+		l := newRateLimiter(3, 1, false)
+
+		Expect(l.wait(context.Background(), false)).To(Succeed())
+		Expect(l.wait(context.Background(), false)).To(Succeed())
+		Expect(l.wait(context.Background(), false)).To(Equal(ErrRateLimited))
+		Expect(l.wait(context.Background(), true)).To(Succeed())
+	})
+
+	It("Should follow server budget", func() {
+		l := newRateLimiter(60, 0, false)
+
+		l.update(http.StatusOK, http.Header{
+			"X-Ratelimit-Limit":     []string{"120"},
+			"X-Ratelimit-Remaining": []string{"1"},
+		})
+		Expect(l.state().Limit).To(Equal(120))
+		Expect(l.wait(context.Background(), false)).To(Succeed())
+		Expect(l.wait(context.Background(), false)).To(Equal(ErrRateLimited))
+	})
+
+	It("Should block after 429 for Retry-After", func() {
+		l := newRateLimiter(60, 0, true)
+
+		l.update(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"1"}})
+		Expect(l.state().RetryAfter).To(BeNumerically(">", 0))
+
+		start := time.Now()
+		Expect(l.wait(context.Background(), true)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically(">=", 900*time.Millisecond))
+	})
+})
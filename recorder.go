@@ -0,0 +1,107 @@
+package bitmex
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// recorderFlush - how often buffered frames are flushed to file, frames
+// received within last period can be lost on crash
+const recorderFlush = time.Second
+
+// Frame - one recorded websocket message with its receive time
+type Frame struct {
+	Time time.Time `json:"t"`
+	Msg  string    `json:"m"`
+}
+
+// Recorder - appends frames to gzip compressed file as JSON lines. Every
+// recording session adds new gzip member, so file stays readable as a whole
+type Recorder struct {
+	mu      sync.Mutex
+	f       *os.File
+	gz      *gzip.Writer
+	enc     *json.Encoder
+	flushed time.Time
+}
+
+// NewRecorder - opens file for appending, creating it if needed
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(f)
+	return &Recorder{
+		f:       f,
+		gz:      gz,
+		enc:     json.NewEncoder(gz),
+		flushed: time.Now(),
+	}, nil
+}
+
+// Record - appends frame received at t
+func (r *Recorder) Record(t time.Time, msg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.enc.Encode(Frame{Time: t, Msg: msg}); err != nil {
+		return err
+	}
+
+	if t.Sub(r.flushed) < recorderFlush {
+		return nil
+	}
+	r.flushed = t
+	return r.gz.Flush()
+}
+
+// Close - flushes remaining frames and closes file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.gz.Close(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// FrameReader - reads frames of recording
+type FrameReader struct {
+	gz  *gzip.Reader
+	buf *bufio.Reader
+}
+
+// NewFrameReader - reads recording from src
+func NewFrameReader(src io.Reader) (*FrameReader, error) {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	return &FrameReader{gz: gz, buf: bufio.NewReader(gz)}, nil
+}
+
+// Next - next frame, io.EOF at the end of recording. Truncated last frame
+// of crashed session is returned as io.ErrUnexpectedEOF
+func (r *FrameReader) Next() (Frame, error) {
+	var frame Frame
+
+	line, err := r.buf.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return frame, err
+	}
+
+	err = json.Unmarshal(line, &frame)
+	return frame, err
+}
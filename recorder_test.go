@@ -0,0 +1,80 @@
+package bitmex_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("Recorder", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "bitmex")
+		Expect(err).To(Succeed())
+		path = filepath.Join(dir, "session.jsonl.gz")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should replay recorded session", func() {
+		srv := bitmextest.NewServer("key", "secret")
+		defer srv.Close()
+
+		// Two sessions appended to the same file
+		for _, price := range []float64{6500, 6501} {
+			rec, err := bitmex.NewRecorder(path)
+			Expect(err).To(Succeed())
+
+			ws := bitmex.NewWS(bitmex.WithBaseURL(srv.URL), bitmex.WithRecorder(rec))
+			Expect(ws.Connect()).To(Succeed())
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			trades := make(chan bitmex.WSTrade, 1)
+			Expect(ws.SubTradeContext(ctx, trades, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+			cancel()
+
+			srv.Insert("trade:XBTUSD", []bitmex.WSTrade{{Symbol: "XBTUSD", Side: "Buy", Size: 1, Price: price}})
+			Eventually(trades).Should(Receive())
+
+			ws.Disconnect()
+			Expect(rec.Close()).To(Succeed())
+		}
+
+		ws := bitmex.NewWS()
+		trades := make(chan bitmex.WSTrade, 4)
+		Expect(ws.SubTrade(trades, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+
+		Expect(ws.ReplayFile(path, 0)).To(Succeed())
+
+		var trade bitmex.WSTrade
+		Expect(trades).To(Receive(&trade))
+		Expect(trade.Price).To(Equal(6500.0))
+		Expect(trades).To(Receive(&trade))
+		Expect(trade.Price).To(Equal(6501.0))
+	})
+
+	It("Should keep pace of recording", func() {
+		rec, err := bitmex.NewRecorder(path)
+		Expect(err).To(Succeed())
+
+		start := time.Now()
+		Expect(rec.Record(start, "pong")).To(Succeed())
+		Expect(rec.Record(start.Add(time.Second), "pong")).To(Succeed())
+		Expect(rec.Close()).To(Succeed())
+
+		began := time.Now()
+		Expect(bitmex.NewWS().ReplayFile(path, 10)).To(Succeed())
+		Expect(time.Since(began)).To(BeNumerically("~", 100*time.Millisecond, 50*time.Millisecond))
+	})
+})
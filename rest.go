@@ -2,15 +2,22 @@ package bitmex
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	uuid "github.com/satori/go.uuid"
@@ -19,72 +26,138 @@ import (
 const (
 	endpoint   = "https://www.bitmex.com"
 	apiVersion = "/api/v1"
+
+	// requests per minute until server reports actual limit
+	defaultRateLimit = 60
 )
 
-// REST API object
+// REST API object, safe for concurrent use by multiple goroutines
 type REST struct {
-	client      *http.Client
+	// accessed atomically, kept first for 64-bit alignment
+	nonce int64
+
+	client  *http.Client
+	baseURL string
+	expires time.Duration
+
+	mu          sync.RWMutex
 	key, secret string
-	nonce       int64
+
+	instruments *Instruments
+	roundOrders bool
+	limiter     *rateLimiter
+	retry       *RetryPolicy
 }
 
 //NewREST REST Bitmex object
-func NewREST() *REST {
-	tr := &http.Transport{
-		MaxIdleConns:    1,
-		IdleConnTimeout: 60 * time.Second,
+func NewREST(opts ...Option) *REST {
+	cfg := newConfig(opts)
+
+	client := cfg.httpClient
+	if client == nil {
+		client = newHTTPClient()
 	}
 
 	return &REST{
-		client: &http.Client{Transport: tr},
-		key:    os.Getenv("BITMEX_KEY"),
-		secret: os.Getenv("BITMEX_SECRET"),
-		nonce:  time.Now().UnixNano() / int64(time.Millisecond),
+		client:  client,
+		baseURL: cfg.restURL,
+		key:     os.Getenv("BITMEX_KEY"),
+		secret:  os.Getenv("BITMEX_SECRET"),
+		nonce:   time.Now().UnixNano() / int64(time.Millisecond),
+		expires: cfg.expires,
+
+		instruments: cfg.instruments,
+		roundOrders: cfg.roundOrders,
+		limiter:     newRateLimiter(defaultRateLimit, cfg.rateReserve, cfg.rateQueue),
+		retry:       cfg.retry,
 	}
 }
 
 // Auth func
 func (r *REST) Auth(key, secret string) {
+	r.mu.Lock()
 	r.key, r.secret = key, secret
+	r.mu.Unlock()
+}
+
+// newHTTPClient - keeps several connections to exchange alive, so concurrent
+// requests don't wait for each other. Dialer and TLS config are left default,
+// so net/http negotiates HTTP/2 when server supports it
+func newHTTPClient() *http.Client {
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{Transport: tr}
 }
 
 //Send order func
 func (r *REST) Send(order *Order) error {
+	return r.SendContext(context.Background(), order)
+}
+
+// SendContext - Send with context
+func (r *REST) SendContext(ctx context.Context, order *Order) error {
+	if err := r.validate(order); err != nil {
+		return err
+	}
 	body, err := json.Marshal(order)
-	req, err := r.request("POST", "/order", body)
+	if err != nil {
+		return err
+	}
+	req, err := r.requestContext(ctx, "POST", "/order", body)
 	if err != nil {
 		return err
 	}
 
-	_, err = r.client.Do(req)
-	return err
+	return r.do(req, nil)
 }
 
-//OrderSend 发送订单 .
+//OrderSend 发送订单 . With retry policy configured the order gets ClOrdID
+//assigned if it has none.
 func (r *REST) OrderSend(order *Order) (Order, error) {
+	return r.OrderSendContext(context.Background(), order)
+}
+
+// OrderSendContext - OrderSend with context
+func (r *REST) OrderSendContext(ctx context.Context, order *Order) (Order, error) {
+	if err := r.validate(order); err != nil {
+		return Order{}, err
+	}
+
+	if r.retry != nil {
+		return r.orderSendRetry(ctx, order)
+	}
+	return r.orderSend(ctx, order)
+}
+
+func (r *REST) orderSend(ctx context.Context, order *Order) (Order, error) {
 	o := Order{}
 	body, err := json.Marshal(order)
-	req, err := r.request("POST", "/order", body)
 	if err != nil {
 		return o, err
 	}
-	resp, err := r.client.Do(req)
+	req, err := r.requestContext(ctx, "POST", "/order", body)
 	if err != nil {
 		return o, err
 	}
-	defer resp.Body.Close()
 
-	respbody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return o, err
-	}
-	fmt.Println(string(respbody))
-	err = json.Unmarshal(respbody, &o)
-	return o, nil
+	err = r.do(req, &o)
+	return o, err
 }
 
 // Order 生成订单的基础方法.
 func (r *REST) Order(symbol string, price float64, amount float64, side, orderType string, postOnly bool) (Order, error) {
+	return r.OrderContext(context.Background(), symbol, price, amount, side, orderType, postOnly)
+}
+
+// OrderContext - Order with context
+func (r *REST) OrderContext(ctx context.Context, symbol string, price float64, amount float64, side, orderType string, postOnly bool) (Order, error) {
 	o := NewOrder(Contract(symbol))
 	o.Price = price
 	o.OrderQty = amount
@@ -94,119 +167,452 @@ func (r *REST) Order(symbol string, price float64, amount float64, side, orderTy
 		o.ExecInst = ParticipateDoNotInitiate
 	}
 
-	return r.OrderSend(o)
+	return r.OrderSendContext(ctx, o)
 
 }
 
 // LimitOrder 限价单.
 func (r *REST) LimitOrder(symbol string, price float64, amount float64, side string, postOnly bool) (Order, error) {
-	return r.Order(symbol, price, amount, side, Limit, postOnly)
+	return r.LimitOrderContext(context.Background(), symbol, price, amount, side, postOnly)
+}
+
+// LimitOrderContext - LimitOrder with context
+func (r *REST) LimitOrderContext(ctx context.Context, symbol string, price float64, amount float64, side string, postOnly bool) (Order, error) {
+	return r.OrderContext(ctx, symbol, price, amount, side, Limit, postOnly)
 }
 
 // LimitBuyOrder 限价单买.
 func (r *REST) LimitBuyOrder(symbol string, price float64, amount float64, postOnly bool) (Order, error) {
-	return r.LimitOrder(symbol, price, amount, "Buy", postOnly)
+	return r.LimitBuyOrderContext(context.Background(), symbol, price, amount, postOnly)
+}
+
+// LimitBuyOrderContext - LimitBuyOrder with context
+func (r *REST) LimitBuyOrderContext(ctx context.Context, symbol string, price float64, amount float64, postOnly bool) (Order, error) {
+	return r.LimitOrderContext(ctx, symbol, price, amount, "Buy", postOnly)
 }
 
 // LimitSellOrder 限价单买.
 func (r *REST) LimitSellOrder(symbol string, price float64, amount float64, postOnly bool) (Order, error) {
-	return r.LimitOrder(symbol, price, amount, "Sell", postOnly)
+	return r.LimitSellOrderContext(context.Background(), symbol, price, amount, postOnly)
+}
+
+// LimitSellOrderContext - LimitSellOrder with context
+func (r *REST) LimitSellOrderContext(ctx context.Context, symbol string, price float64, amount float64, postOnly bool) (Order, error) {
+	return r.LimitOrderContext(ctx, symbol, price, amount, "Sell", postOnly)
 }
 
 // MarketOrder 市价单.
 func (r *REST) MarketOrder(symbol string, price float64, amount float64, side string) (Order, error) {
-	return r.Order(symbol, price, amount, side, Market, false)
+	return r.MarketOrderContext(context.Background(), symbol, price, amount, side)
+}
+
+// MarketOrderContext - MarketOrder with context
+func (r *REST) MarketOrderContext(ctx context.Context, symbol string, price float64, amount float64, side string) (Order, error) {
+	return r.OrderContext(ctx, symbol, price, amount, side, Market, false)
 }
 
 // MarketBuyOrder 市价买单.
 func (r *REST) MarketBuyOrder(symbol string, price float64, amount float64) (Order, error) {
-	return r.Order(symbol, price, amount, "Buy", Market, false)
+	return r.MarketBuyOrderContext(context.Background(), symbol, price, amount)
+}
+
+// MarketBuyOrderContext - MarketBuyOrder with context
+func (r *REST) MarketBuyOrderContext(ctx context.Context, symbol string, price float64, amount float64) (Order, error) {
+	return r.OrderContext(ctx, symbol, price, amount, "Buy", Market, false)
 }
 
 // MarketSellOrder 市价买单.
 func (r *REST) MarketSellOrder(symbol string, price float64, amount float64) (Order, error) {
-	return r.Order(symbol, price, amount, "Sell", Market, false)
+	return r.MarketSellOrderContext(context.Background(), symbol, price, amount)
+}
+
+// MarketSellOrderContext - MarketSellOrder with context
+func (r *REST) MarketSellOrderContext(ctx context.Context, symbol string, price float64, amount float64) (Order, error) {
+	return r.OrderContext(ctx, symbol, price, amount, "Sell", Market, false)
 }
 
 // CancelOrder 取消订单.
 func (r *REST) CancelOrder(orderID uuid.UUID) error {
+	return r.CancelOrderContext(context.Background(), orderID)
+}
+
+// CancelOrderContext - CancelOrder with context
+func (r *REST) CancelOrderContext(ctx context.Context, orderID uuid.UUID) error {
 	o := Order{}
 	o.OrderID = orderID
 	body, err := json.Marshal(o)
-	req, err := r.request("DELETE", "/order", body)
 	if err != nil {
 		return err
 	}
-	resp, err := r.client.Do(req)
+	req, err := r.requestContext(ctx, "DELETE", "/order", body)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	respbody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(respbody))
-	return nil
+	return r.do(req, nil)
 }
 
 // ModifyOrder 修改订单.
 func (r *REST) ModifyOrder(order Order) (Order, error) {
+	return r.ModifyOrderContext(context.Background(), order)
+}
+
+// ModifyOrderContext - ModifyOrder with context
+func (r *REST) ModifyOrderContext(ctx context.Context, order Order) (Order, error) {
 	o := Order{}
 	body, err := json.Marshal(order)
-	req, err := r.request("PUT", "/order", body)
 	if err != nil {
 		return o, err
 	}
-	resp, err := r.client.Do(req)
+	req, err := r.requestContext(ctx, "PUT", "/order", body)
 	if err != nil {
 		return o, err
 	}
+
+	err = r.do(req, &o)
+	return o, err
+}
+
+// ErrNilOrder - nil entry of bulk request
+var ErrNilOrder = errors.New("bitmex: nil order")
+
+// PlaceOrders 批量下单, rejected orders come back with OrdStatus "Rejected"
+// and OrdRejReason instead of failing whole request. Orders failing local
+// validation, and nil entries, are rejected the same way and not sent.
+// Results are in order of orders.
+func (r *REST) PlaceOrders(orders []*Order) ([]Order, error) {
+	return r.PlaceOrdersContext(context.Background(), orders)
+}
+
+// PlaceOrdersContext - PlaceOrders with context
+func (r *REST) PlaceOrdersContext(ctx context.Context, orders []*Order) ([]Order, error) {
+	res := make([]Order, len(orders))
+	var valid []*Order
+	var positions []int
+
+	for i, order := range orders {
+		if order == nil {
+			res[i] = rejected(Order{}, ErrNilOrder)
+			continue
+		}
+		if err := r.validate(order); err != nil {
+			res[i] = rejected(*order, err)
+			continue
+		}
+		valid = append(valid, order)
+		positions = append(positions, i)
+	}
+
+	if len(valid) == 0 {
+		return res, nil
+	}
+
+	body, err := json.Marshal(map[string][]*Order{"orders": valid})
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.requestContext(ctx, "POST", "/order/bulk", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []Order
+	if err := r.do(req, &sent); err != nil {
+		return nil, err
+	}
+
+	// Server answers every order in order they were sent
+	for i, one := range sent {
+		if i < len(positions) {
+			res[positions[i]] = one
+		}
+	}
+	return res, nil
+}
+
+// AmendOrders 批量修改订单. Amends with Symbol are validated like orders of
+// PlaceOrders and rejected the same way, results are in order of orders.
+func (r *REST) AmendOrders(orders []Order) ([]Order, error) {
+	return r.AmendOrdersContext(context.Background(), orders)
+}
+
+// AmendOrdersContext - AmendOrders with context
+func (r *REST) AmendOrdersContext(ctx context.Context, orders []Order) ([]Order, error) {
+	res := make([]Order, len(orders))
+	var valid []Order
+	var positions []int
+
+	for i := range orders {
+		if err := r.validateAmend(&orders[i]); err != nil {
+			res[i] = rejected(orders[i], err)
+			continue
+		}
+		valid = append(valid, orders[i])
+		positions = append(positions, i)
+	}
+
+	if len(valid) == 0 {
+		return res, nil
+	}
+
+	body, err := json.Marshal(map[string][]Order{"orders": valid})
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.requestContext(ctx, "PUT", "/order/bulk", body)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent []Order
+	if err := r.do(req, &sent); err != nil {
+		return nil, err
+	}
+
+	for i, one := range sent {
+		if i < len(positions) {
+			res[positions[i]] = one
+		}
+	}
+	return res, nil
+}
+
+// rejected - order failed before sending, reported like exchange rejection
+func rejected(order Order, err error) Order {
+	order.OrdStatus = "Rejected"
+	order.OrdRejReason = err.Error()
+	return order
+}
+
+// CancelOrders 批量取消订单, orders which could not be cancelled have Error set.
+func (r *REST) CancelOrders(orderIDs []uuid.UUID, clOrdIDs []string) ([]Order, error) {
+	return r.CancelOrdersContext(context.Background(), orderIDs, clOrdIDs)
+}
+
+// CancelOrdersContext - CancelOrders with context
+func (r *REST) CancelOrdersContext(ctx context.Context, orderIDs []uuid.UUID, clOrdIDs []string) ([]Order, error) {
+	var res []Order
+	body, err := json.Marshal(struct {
+		OrderID []uuid.UUID `json:"orderID,omitempty"`
+		ClOrdID []string    `json:"clOrdID,omitempty"`
+	}{orderIDs, clOrdIDs})
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.requestContext(ctx, "DELETE", "/order", body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.do(req, &res)
+	return res, err
+}
+
+// CancelAll 取消全部订单, empty symbol cancels orders of all contracts,
+// filter narrows cancelled orders, e.g. {"side": "Buy"}.
+func (r *REST) CancelAll(symbol Contract, filter map[string]interface{}) ([]Order, error) {
+	return r.CancelAllContext(context.Background(), symbol, filter)
+}
+
+// CancelAllContext - CancelAll with context
+func (r *REST) CancelAllContext(ctx context.Context, symbol Contract, filter map[string]interface{}) ([]Order, error) {
+	var res []Order
+	body, err := json.Marshal(struct {
+		Symbol Contract               `json:"symbol,omitempty"`
+		Filter map[string]interface{} `json:"filter,omitempty"`
+	}{symbol, filter})
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.requestContext(ctx, "DELETE", "/order/all", body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.do(req, &res)
+	return res, err
+}
+
+// Orders 查询订单.
+func (r *REST) Orders(q *Query) ([]Order, error) {
+	return r.OrdersContext(context.Background(), q)
+}
+
+// OrdersContext - Orders with context
+func (r *REST) OrdersContext(ctx context.Context, q *Query) ([]Order, error) {
+	var orders []Order
+	values, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	err = r.get(ctx, "/order", values, &orders)
+	return orders, err
+}
+
+// TradeHistory 成交记录, paginated by q.Count and q.Start.
+func (r *REST) TradeHistory(q *Query) ([]Execution, error) {
+	return r.TradeHistoryContext(context.Background(), q)
+}
+
+// TradeHistoryContext - TradeHistory with context
+func (r *REST) TradeHistoryContext(ctx context.Context, q *Query) ([]Execution, error) {
+	var executions []Execution
+	values, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	err = r.get(ctx, "/execution/tradeHistory", values, &executions)
+	return executions, err
+}
+
+// ActiveInstruments 可交易合约.
+func (r *REST) ActiveInstruments() ([]Instrument, error) {
+	return r.ActiveInstrumentsContext(context.Background())
+}
+
+// ActiveInstrumentsContext - ActiveInstruments with context
+func (r *REST) ActiveInstrumentsContext(ctx context.Context) ([]Instrument, error) {
+	var instruments []Instrument
+	err := r.get(ctx, "/instrument/active", nil, &instruments)
+	return instruments, err
+}
+
+// validate checks order against instrument registry if it is configured
+func (r *REST) validate(order *Order) error {
+	if r.instruments == nil {
+		return nil
+	}
+	if r.roundOrders {
+		return r.instruments.Round(order)
+	}
+	return r.instruments.Validate(order)
+}
+
+// validateAmend - amend carries changed fields only, so it is checked without
+// rounding and only when Symbol tells its instrument
+func (r *REST) validateAmend(order *Order) error {
+	if r.instruments == nil || order.Symbol == "" {
+		return nil
+	}
+	return r.instruments.Validate(order)
+}
+
+func (r *REST) getNonce() int64 {
+	return atomic.AddInt64(&r.nonce, 1)
+}
+
+// get performs signed GET request and decodes JSON response into v
+func (r *REST) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	if encoded := query.Encode(); encoded != "" {
+		path += "?" + encoded
+	}
+
+	req, err := r.requestContext(ctx, "GET", path, nil)
+	if err != nil {
+		return err
+	}
+
+	return r.do(req, v)
+}
+
+// call sends in as JSON body and decodes response into out
+func (r *REST) call(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := r.requestContext(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	return r.do(req, out)
+}
+
+// do executes request, non 2xx responses are returned as *APIError,
+// successful response is decoded into v unless it is nil
+func (r *REST) do(req *http.Request, v interface{}) error {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
 	defer resp.Body.Close()
 
+	r.limiter.update(resp.StatusCode, resp.Header)
+
 	respbody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return o, err
+		return err
 	}
-	fmt.Println(string(respbody))
-	err = json.Unmarshal(respbody, &o)
-	return o, nil
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(req, resp, respbody)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(respbody, v)
 }
 
-func (r *REST) getNonce() int64 {
-	r.nonce++
-	return r.nonce
+// request builds signed request without deadline
+func (r *REST) request(method, path string, body []byte) (*http.Request, error) {
+	return r.requestContext(context.Background(), method, path, body)
 }
 
-func (r *REST) request(method, url string, body []byte) (*http.Request, error) {
+// requestContext builds signed request bound to ctx, for GET path may contain
+// encoded query string. It waits for rate limit budget first, so expiration
+// (or nonce) is taken right before sending
+func (r *REST) requestContext(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	cancel := method == "DELETE" || strings.HasPrefix(path, "/order/cancelAllAfter")
+	if err := r.limiter.wait(ctx, cancel); err != nil {
+		return nil, err
+	}
 
-	if method == "GET" {
-		// TODO
-		return nil, nil
+	var bodyReader io.Reader
+	if method != "GET" {
+		bodyReader = bytes.NewReader(body)
+	} else {
+		body = nil
 	}
+
 	req, err := http.NewRequest(
-		method, endpoint+apiVersion+url, bytes.NewReader(body),
+		method, r.baseURL+apiVersion+path, bodyReader,
 	)
 
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
+
+	r.mu.RLock()
+	key, secret := r.key, r.secret
+	r.mu.RUnlock()
+
+	var sig string
+	if r.expires > 0 {
+		expires := time.Now().Add(r.expires).Unix()
+		sig = signature(secret, method, path, expires, body)
+		req.Header.Add("api-expires", strconv.FormatInt(expires, 10))
+	} else {
+		nonce := r.getNonce()
+		sig = signature(secret, method, path, nonce, body)
+		req.Header.Add("api-nonce", strconv.FormatInt(nonce, 10))
+	}
 
-	nonce := r.getNonce()
-	sig := signature(r.secret, method, url, nonce, body)
-
-	req.Header.Add("Content-Length", strconv.Itoa(len(body)))
-	req.Header.Add("Content-Type", "application/json")
+	if method != "GET" {
+		req.Header.Add("Content-Length", strconv.Itoa(len(body)))
+		req.Header.Add("Content-Type", "application/json")
+	}
 
-	req.Header.Add("api-nonce", strconv.FormatInt(nonce, 10))
-	req.Header.Add("api-key", r.key)
+	req.Header.Add("api-key", key)
 	req.Header.Add("api-signature", sig)
 
 	return req, nil
 }
 
-// hex(HMAC_SHA256(apiSecret, verb + path + nonce + data))
+// hex(HMAC_SHA256(apiSecret, verb + path + nonce + data)), api-expires
+// timestamp is signed in place of nonce
 func signature(secret, verb, path string, nonce int64, data []byte) string {
 	mac := hmac.New(sha256.New, []byte(secret))
 	var buf bytes.Buffer
@@ -0,0 +1,94 @@
+package bitmex
+
+import (
+	"context"
+	"net/url"
+)
+
+func currencyValues(currency string) url.Values {
+	if currency == "" {
+		currency = XBt
+	}
+	return url.Values{"currency": []string{currency}}
+}
+
+// currencyQuery - parameters of q, currency defaults to XBt
+func currencyQuery(q *Query) (url.Values, error) {
+	values, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	if values.Get("currency") == "" {
+		values.Set("currency", XBt)
+	}
+	return values, nil
+}
+
+// User 用户信息.
+func (r *REST) User() (User, error) {
+	return r.UserContext(context.Background())
+}
+
+// UserContext - User with context
+func (r *REST) UserContext(ctx context.Context) (User, error) {
+	var u User
+	err := r.get(ctx, "/user", nil, &u)
+	return u, err
+}
+
+// Wallet 钱包余额, empty currency means XBt.
+func (r *REST) Wallet(currency string) (Wallet, error) {
+	return r.WalletContext(context.Background(), currency)
+}
+
+// WalletContext - Wallet with context
+func (r *REST) WalletContext(ctx context.Context, currency string) (Wallet, error) {
+	var w Wallet
+	err := r.get(ctx, "/user/wallet", currencyValues(currency), &w)
+	return w, err
+}
+
+// Margin 保证金状态, empty currency means XBt.
+func (r *REST) Margin(currency string) (Margin, error) {
+	return r.MarginContext(context.Background(), currency)
+}
+
+// MarginContext - Margin with context
+func (r *REST) MarginContext(ctx context.Context, currency string) (Margin, error) {
+	var m Margin
+	err := r.get(ctx, "/user/margin", currencyValues(currency), &m)
+	return m, err
+}
+
+// WalletSummary 钱包汇总 of XBt.
+func (r *REST) WalletSummary() ([]Transaction, error) {
+	return r.WalletSummaryContext(context.Background(), nil)
+}
+
+// WalletSummaryContext - WalletSummary with context, q.Currency selects
+// other currency
+func (r *REST) WalletSummaryContext(ctx context.Context, q *Query) ([]Transaction, error) {
+	var res []Transaction
+	values, err := currencyQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	err = r.get(ctx, "/user/walletSummary", values, &res)
+	return res, err
+}
+
+// WalletHistory 钱包流水, paginated by q.Count and q.Start.
+func (r *REST) WalletHistory(q *Query) ([]Transaction, error) {
+	return r.WalletHistoryContext(context.Background(), q)
+}
+
+// WalletHistoryContext - WalletHistory with context
+func (r *REST) WalletHistoryContext(ctx context.Context, q *Query) ([]Transaction, error) {
+	var res []Transaction
+	values, err := currencyQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	err = r.get(ctx, "/user/walletHistory", values, &res)
+	return res, err
+}
@@ -0,0 +1,97 @@
+package bitmex
+
+import "context"
+
+// Positions 查询仓位.
+func (r *REST) Positions(q *Query) ([]Position, error) {
+	return r.PositionsContext(context.Background(), q)
+}
+
+// PositionsContext - Positions with context
+func (r *REST) PositionsContext(ctx context.Context, q *Query) ([]Position, error) {
+	var positions []Position
+	values, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	err = r.get(ctx, "/position", values, &positions)
+	return positions, err
+}
+
+// SetLeverage 设置杠杆, zero leverage switches position to cross margin.
+func (r *REST) SetLeverage(symbol Contract, leverage float64) (Position, error) {
+	return r.SetLeverageContext(context.Background(), symbol, leverage)
+}
+
+// SetLeverageContext - SetLeverage with context
+func (r *REST) SetLeverageContext(ctx context.Context, symbol Contract, leverage float64) (Position, error) {
+	var p Position
+	err := r.call(ctx, "POST", "/position/leverage", map[string]interface{}{
+		"symbol":   symbol,
+		"leverage": leverage,
+	}, &p)
+	return p, err
+}
+
+// SetIsolated 切换逐仓/全仓.
+func (r *REST) SetIsolated(symbol Contract, enabled bool) (Position, error) {
+	return r.SetIsolatedContext(context.Background(), symbol, enabled)
+}
+
+// SetIsolatedContext - SetIsolated with context
+func (r *REST) SetIsolatedContext(ctx context.Context, symbol Contract, enabled bool) (Position, error) {
+	var p Position
+	err := r.call(ctx, "POST", "/position/isolate", map[string]interface{}{
+		"symbol":  symbol,
+		"enabled": enabled,
+	}, &p)
+	return p, err
+}
+
+// TransferMargin 调整逐仓保证金, amount in satoshis, negative removes margin.
+func (r *REST) TransferMargin(symbol Contract, amount int64) (Position, error) {
+	return r.TransferMarginContext(context.Background(), symbol, amount)
+}
+
+// TransferMarginContext - TransferMargin with context
+func (r *REST) TransferMarginContext(ctx context.Context, symbol Contract, amount int64) (Position, error) {
+	var p Position
+	err := r.call(ctx, "POST", "/position/transferMargin", map[string]interface{}{
+		"symbol": symbol,
+		"amount": amount,
+	}, &p)
+	return p, err
+}
+
+// SetRiskLimit 设置风险限额, limit in satoshis.
+func (r *REST) SetRiskLimit(symbol Contract, limit int64) (Position, error) {
+	return r.SetRiskLimitContext(context.Background(), symbol, limit)
+}
+
+// SetRiskLimitContext - SetRiskLimit with context
+func (r *REST) SetRiskLimitContext(ctx context.Context, symbol Contract, limit int64) (Position, error) {
+	var p Position
+	err := r.call(ctx, "POST", "/position/riskLimit", map[string]interface{}{
+		"symbol":    symbol,
+		"riskLimit": limit,
+	}, &p)
+	return p, err
+}
+
+// ClosePosition 平仓, zero price closes position by market order.
+func (r *REST) ClosePosition(symbol Contract, price float64) (Order, error) {
+	return r.ClosePositionContext(context.Background(), symbol, price)
+}
+
+// ClosePositionContext - ClosePosition with context
+func (r *REST) ClosePositionContext(ctx context.Context, symbol Contract, price float64) (Order, error) {
+	o := NewOrder(symbol)
+	o.ExecInst = Close
+	o.OrdType = Market
+	if price != 0 {
+		o.OrdType = Limit
+		o.Price = price
+	}
+
+	return r.OrderSendContext(ctx, o)
+}
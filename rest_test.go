@@ -1,10 +1,18 @@
 package bitmex
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	. "github.com/onsi/ginkgo"
@@ -62,6 +70,98 @@ var _ = Describe("Rest", func() {
 			Expect(buf).NotTo(BeEmpty())
 		})
 
+		It("Should make signed GET request", func() {
+			b := NewREST()
+			b.Auth("key", "secret")
+
+			q := &Query{
+				Symbol:  XBTUSD,
+				Filter:  map[string]interface{}{"open": true},
+				Count:   10,
+				Reverse: true,
+			}
+			values, err := q.Values()
+			Expect(err).To(Succeed())
+
+			path := "/order?" + values.Encode()
+			Expect(path).To(Equal(`/order?count=10&filter=%7B%22open%22%3Atrue%7D&reverse=true&symbol=XBTUSD`))
+
+			req, err := b.request("GET", path, nil)
+			Expect(err).To(Succeed())
+			Expect(req.URL.RequestURI()).To(Equal(apiVersion + path))
+			Expect(req.Body).To(BeNil())
+
+			expires, err := strconv.ParseInt(req.Header.Get("api-expires"), 10, 64)
+			Expect(err).To(Succeed())
+			Expect(time.Unix(expires, 0)).To(BeTemporally("~", time.Now().Add(defaultExpires), time.Second))
+			Expect(req.Header.Get("api-nonce")).To(BeEmpty())
+			Expect(req.Header.Get("api-signature")).To(Equal(
+				signature("secret", "GET", path, expires, nil),
+			))
+		})
+
+		It("Should sign with legacy nonce", func() {
+			b := NewREST(WithLegacyNonce())
+			b.Auth("key", "secret")
+
+			req, err := b.request("POST", "/order", []byte(`{}`))
+			Expect(err).To(Succeed())
+			Expect(req.Header.Get("api-expires")).To(BeEmpty())
+
+			nonce, err := strconv.ParseInt(req.Header.Get("api-nonce"), 10, 64)
+			Expect(err).To(Succeed())
+			Expect(req.Header.Get("api-signature")).To(Equal(
+				signature("secret", "POST", "/order", nonce, []byte(`{}`)),
+			))
+
+			ws := NewWS(WithLegacyNonce())
+			Expect(ws.authMessage()).To(HavePrefix(`{"op": "authKey", "args": [`))
+			Expect(NewWS().authMessage()).To(HavePrefix(`{"op": "authKeyExpires", "args": [`))
+		})
+
+		It("Should not repeat WS nonce under concurrent use", func() {
+			ws := NewWS(WithLegacyNonce())
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			seen := make(map[int64]bool)
+
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						nonce := ws.Nonce()
+						mu.Lock()
+						seen[nonce] = true
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+
+			Expect(seen).To(HaveLen(800))
+		})
+
+		It("Should use configured base URL", func() {
+			b := NewREST(WithBaseURL("http://127.0.0.1:8080/"))
+			b.Auth("key", "secret")
+
+			req, err := b.request("POST", "/order", []byte(`{}`))
+			Expect(err).To(Succeed())
+			Expect(req.URL.String()).To(Equal("http://127.0.0.1:8080/api/v1/order"))
+
+			expires, err := strconv.ParseInt(req.Header.Get("api-expires"), 10, 64)
+			Expect(err).To(Succeed())
+			Expect(req.Header.Get("api-signature")).To(Equal(
+				signature("secret", "POST", "/order", expires, []byte(`{}`)),
+			))
+
+			Expect(NewWS(WithBaseURL("http://127.0.0.1:8080")).url).To(Equal("ws://127.0.0.1:8080/realtime"))
+			Expect(NewWS(Testnet()).url).To(Equal(testnetWSURL))
+			Expect(NewREST(Testnet()).baseURL).To(Equal(testnetEndpoint))
+		})
+
 		It("Should send", func() {
 			b := NewREST()
 
@@ -71,4 +171,526 @@ var _ = Describe("Rest", func() {
 			Expect(err).To(Succeed())
 		})
 	})
+
+	Context("Bulk", func() {
+		It("Should reject invalid orders and send valid ones", func() {
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body, _ = ioutil.ReadAll(req.Body)
+				w.Write([]byte(`[{"clOrdID":"b","price":6500.5,"ordStatus":"New"}]`))
+			}))
+			defer srv.Close()
+
+			instruments := NewInstruments()
+			instruments.apply(wsData{Action: "partial", Data: []byte(`[{"symbol":"XBTUSD","tickSize":0.5,"lotSize":1,"maxOrderQty":10000000}]`)})
+
+			invalid := NewOrder(XBTUSD)
+			invalid.ClOrdID = "a"
+			invalid.OrderQty = 100
+			invalid.Price = 6500.3
+
+			valid := NewOrder(XBTUSD)
+			valid.ClOrdID = "b"
+			valid.OrderQty = 100
+			valid.Price = 6500.5
+
+			b := NewREST(WithBaseURL(srv.URL), WithOrderValidation(instruments, false))
+			orders, err := b.PlaceOrders([]*Order{invalid, valid})
+
+			Expect(err).To(Succeed())
+			Expect(string(body)).NotTo(ContainSubstring(`"a"`))
+			Expect(string(body)).To(ContainSubstring(`"b"`))
+
+			Expect(orders).To(HaveLen(2))
+			Expect(orders[0].ClOrdID).To(Equal("a"))
+			Expect(orders[0].OrdStatus).To(Equal("Rejected"))
+			Expect(orders[0].OrdRejReason).To(ContainSubstring("tick size"))
+			Expect(orders[1].ClOrdID).To(Equal("b"))
+			Expect(orders[1].OrdStatus).To(Equal("New"))
+		})
+
+		It("Should reject nil orders individually", func() {
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body, _ = ioutil.ReadAll(req.Body)
+				w.Write([]byte(`[{"clOrdID":"b","ordStatus":"New"}]`))
+			}))
+			defer srv.Close()
+
+			valid := NewOrder(XBTUSD)
+			valid.ClOrdID = "b"
+			valid.OrderQty = 100
+
+			orders, err := NewREST(WithBaseURL(srv.URL)).PlaceOrders([]*Order{nil, valid})
+
+			Expect(err).To(Succeed())
+			Expect(string(body)).NotTo(ContainSubstring("null"))
+			Expect(orders).To(HaveLen(2))
+			Expect(orders[0].OrdStatus).To(Equal("Rejected"))
+			Expect(orders[0].OrdRejReason).To(Equal(ErrNilOrder.Error()))
+			Expect(orders[1].OrdStatus).To(Equal("New"))
+		})
+
+		It("Should reject invalid amends and send valid ones", func() {
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.Method).To(Equal("PUT"))
+				Expect(req.URL.Path).To(Equal(apiVersion + "/order/bulk"))
+				body, _ = ioutil.ReadAll(req.Body)
+				w.Write([]byte(`[{"clOrdID":"b","price":6400,"ordStatus":"New"},{"clOrdID":"c","orderQty":50,"ordStatus":"New"}]`))
+			}))
+			defer srv.Close()
+
+			instruments := NewInstruments()
+			instruments.apply(wsData{Action: "partial", Data: []byte(`[{"symbol":"XBTUSD","tickSize":0.5,"lotSize":1,"maxOrderQty":10000000}]`)})
+
+			b := NewREST(WithBaseURL(srv.URL), WithOrderValidation(instruments, true))
+			orders, err := b.AmendOrders([]Order{
+				{ClOrdID: "a", Symbol: XBTUSD, Price: 6400.3},
+				{ClOrdID: "b", Symbol: XBTUSD, Price: 6400},
+				{ClOrdID: "c", OrderQty: 50},
+			})
+
+			Expect(err).To(Succeed())
+			Expect(string(body)).NotTo(ContainSubstring(`"a"`))
+			Expect(string(body)).To(ContainSubstring(`"b"`))
+			Expect(string(body)).To(ContainSubstring(`"c"`))
+
+			Expect(orders).To(HaveLen(3))
+			Expect(orders[0].OrdStatus).To(Equal("Rejected"))
+			Expect(orders[0].OrdRejReason).To(ContainSubstring("tick size"))
+			Expect(orders[1].Price).To(Equal(6400.0))
+			Expect(orders[2].OrderQty).To(Equal(50.0))
+		})
+
+		It("Should return per order results", func() {
+			var body []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.Method).To(Equal("DELETE"))
+				Expect(req.URL.Path).To(Equal(apiVersion + "/order"))
+				body, _ = ioutil.ReadAll(req.Body)
+
+				w.Write([]byte(`[
+					{"clOrdID":"a","ordStatus":"Canceled"},
+					{"clOrdID":"b","ordStatus":"Filled","error":"Unable to cancel order due to existing state: Filled"}
+				]`))
+			}))
+			defer srv.Close()
+
+			b := NewREST(WithBaseURL(srv.URL))
+			orders, err := b.CancelOrders(nil, []string{"a", "b"})
+
+			Expect(err).To(Succeed())
+			Expect(string(body)).To(Equal(`{"clOrdID":["a","b"]}`))
+			Expect(orders).To(HaveLen(2))
+			Expect(orders[0].Error).To(BeEmpty())
+			Expect(orders[1].Error).To(ContainSubstring("Filled"))
+		})
+	})
+
+	Context("Position", func() {
+		var srv *httptest.Server
+		var method, uri string
+		var body map[string]interface{}
+		var reply string
+
+		BeforeEach(func() {
+			body = nil
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				method, uri = req.Method, req.URL.RequestURI()
+				json.NewDecoder(req.Body).Decode(&body)
+				w.Write([]byte(reply))
+			}))
+		})
+
+		AfterEach(func() {
+			srv.Close()
+		})
+
+		It("Should list positions", func() {
+			reply = `[{"symbol":"XBTUSD","currentQty":-100,"isOpen":true,"leverage":10,"crossMargin":false}]`
+
+			positions, err := NewREST(WithBaseURL(srv.URL)).Positions(&Query{
+				Filter: map[string]interface{}{"isOpen": true},
+			})
+
+			Expect(err).To(Succeed())
+			Expect(method).To(Equal("GET"))
+			Expect(uri).To(HavePrefix(apiVersion + "/position?"))
+			Expect(uri).To(ContainSubstring("isOpen"))
+			Expect(body).To(BeNil())
+
+			Expect(positions).To(HaveLen(1))
+			Expect(positions[0].Symbol).To(Equal(XBTUSD))
+			Expect(positions[0].CurrentQty).To(BeEquivalentTo(-100))
+			Expect(positions[0].IsOpen).To(BeTrue())
+		})
+
+		It("Should set leverage", func() {
+			reply = `{"symbol":"XBTUSD","leverage":25,"crossMargin":false}`
+
+			p, err := NewREST(WithBaseURL(srv.URL)).SetLeverage(XBTUSD, 25)
+
+			Expect(err).To(Succeed())
+			Expect(method).To(Equal("POST"))
+			Expect(uri).To(Equal(apiVersion + "/position/leverage"))
+			Expect(body).To(Equal(map[string]interface{}{"symbol": "XBTUSD", "leverage": 25.0}))
+			Expect(p.Leverage).To(Equal(25.0))
+		})
+
+		It("Should switch isolated margin", func() {
+			reply = `{"symbol":"XBTUSD","crossMargin":true}`
+
+			p, err := NewREST(WithBaseURL(srv.URL)).SetIsolated(XBTUSD, false)
+
+			Expect(err).To(Succeed())
+			Expect(method).To(Equal("POST"))
+			Expect(uri).To(Equal(apiVersion + "/position/isolate"))
+			Expect(body).To(Equal(map[string]interface{}{"symbol": "XBTUSD", "enabled": false}))
+			Expect(p.CrossMargin).To(BeTrue())
+		})
+
+		It("Should set risk limit", func() {
+			reply = `{"symbol":"XBTUSD","riskLimit":30000000000}`
+
+			p, err := NewREST(WithBaseURL(srv.URL)).SetRiskLimit(XBTUSD, 30000000000)
+
+			Expect(err).To(Succeed())
+			Expect(method).To(Equal("POST"))
+			Expect(uri).To(Equal(apiVersion + "/position/riskLimit"))
+			Expect(body).To(Equal(map[string]interface{}{"symbol": "XBTUSD", "riskLimit": 30000000000.0}))
+			Expect(p.RiskLimit).To(BeEquivalentTo(30000000000))
+		})
+
+		It("Should transfer margin", func() {
+			reply = `{"symbol":"XBTUSD","posMargin":1500000}`
+
+			p, err := NewREST(WithBaseURL(srv.URL)).TransferMargin(XBTUSD, -500000)
+
+			Expect(err).To(Succeed())
+			Expect(method).To(Equal("POST"))
+			Expect(uri).To(Equal(apiVersion + "/position/transferMargin"))
+			Expect(body).To(Equal(map[string]interface{}{"symbol": "XBTUSD", "amount": -500000.0}))
+			Expect(p.PosMargin).To(BeEquivalentTo(1500000))
+		})
+	})
+
+	Context("Account", func() {
+		It("Should fetch wallet in satoshis", func() {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.RequestURI()).To(Equal(apiVersion + "/user/wallet?currency=XBt"))
+				w.Write([]byte(`{"account":1,"currency":"XBt","amount":150000000}`))
+			}))
+			defer srv.Close()
+
+			wallet, err := NewREST(WithBaseURL(srv.URL)).Wallet("")
+
+			Expect(err).To(Succeed())
+			Expect(wallet.Amount).To(Equal(Satoshi(150000000)))
+			Expect(wallet.Amount.XBT()).To(Equal(1.5))
+			Expect(SatoshiFromXBT(1.5)).To(Equal(wallet.Amount))
+		})
+
+		It("Should fetch wallet summary of currency", func() {
+			var uris []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				uris = append(uris, req.URL.RequestURI())
+				w.Write([]byte(`[{"account":1,"currency":"XBt","transactType":"Total","amount":150000000}]`))
+			}))
+			defer srv.Close()
+
+			b := NewREST(WithBaseURL(srv.URL))
+			summary, err := b.WalletSummary()
+			Expect(err).To(Succeed())
+			Expect(summary).To(HaveLen(1))
+			Expect(summary[0].TransactType).To(Equal("Total"))
+
+			_, err = b.WalletSummaryContext(context.Background(), &Query{Currency: "USDt"})
+			Expect(err).To(Succeed())
+
+			Expect(uris).To(Equal([]string{
+				apiVersion + "/user/walletSummary?currency=XBt",
+				apiVersion + "/user/walletSummary?currency=USDt",
+			}))
+		})
+
+		It("Should fetch trade history with fees", func() {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				Expect(req.URL.Path).To(Equal(apiVersion + "/execution/tradeHistory"))
+				Expect(req.URL.Query().Get("count")).To(Equal("2"))
+				Expect(req.URL.Query().Get("start")).To(Equal("100"))
+
+				w.Write([]byte(`[{
+					"execID":"e1","orderID":"7bb6ec6a-4d9b-4d8b-a7e4-0a4c7f5e3d2a","symbol":"XBTUSD",
+					"side":"Buy","lastQty":100,"lastPx":6500.5,"lastLiquidityInd":"AddedLiquidity",
+					"execType":"Trade","commission":-0.00025,"execCost":-1538343,"execComm":-384
+				}]`))
+			}))
+			defer srv.Close()
+
+			execs, err := NewREST(WithBaseURL(srv.URL)).TradeHistory(&Query{Count: 2, Start: 100})
+
+			Expect(err).To(Succeed())
+			Expect(execs).To(HaveLen(1))
+			Expect(execs[0].IsMaker()).To(BeTrue())
+			Expect(execs[0].ExecComm).To(Equal(Satoshi(-384)))
+			Expect(execs[0].LastPx).To(Equal(6500.5))
+		})
+	})
+
+	Context("Retry", func() {
+		It("Should retry overloaded and look up ambiguous order", func() {
+			var posts, gets int
+			var clOrdID string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.Method {
+				case "POST":
+					posts++
+					var o Order
+					json.NewDecoder(req.Body).Decode(&o)
+					clOrdID = o.ClOrdID
+
+					if posts == 1 {
+						w.WriteHeader(http.StatusServiceUnavailable)
+						w.Write([]byte(`{"error":{"message":"The system is currently overloaded. Please try again later.","name":"HTTPError"}}`))
+						return
+					}
+
+					// Order lands but connection drops before response
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+
+				case "GET":
+					gets++
+					Expect(req.URL.Query().Get("filter")).To(ContainSubstring(clOrdID))
+					w.Write([]byte(`[{"clOrdID":"` + clOrdID + `","ordStatus":"New"}]`))
+				}
+			}))
+			defer srv.Close()
+
+			b := NewREST(WithBaseURL(srv.URL), WithRetry(RetryPolicy{
+				MaxAttempts: 3,
+				MinBackoff:  time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+			}))
+
+			order := NewOrderMarket(XBTUSD, 1)
+			o, err := b.OrderSend(order)
+
+			Expect(err).To(Succeed())
+			Expect(order.ClOrdID).NotTo(BeEmpty())
+			Expect(o.ClOrdID).To(Equal(order.ClOrdID))
+			Expect(o.OrdStatus).To(Equal("New"))
+			Expect(posts).To(Equal(2))
+			Expect(gets).To(Equal(1))
+		})
+
+		It("Should return order placed in flight when resend is duplicate", func() {
+			var posts, gets int
+			var clOrdID string
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch req.Method {
+				case "POST":
+					posts++
+					var o Order
+					json.NewDecoder(req.Body).Decode(&o)
+					clOrdID = o.ClOrdID
+
+					if posts == 1 {
+						// Still being placed when connection drops
+						conn, _, _ := w.(http.Hijacker).Hijack()
+						conn.Close()
+						return
+					}
+
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error":{"message":"Duplicate clOrdID","name":"HTTPError"}}`))
+
+				case "GET":
+					gets++
+					if gets == 1 {
+						w.Write([]byte(`[]`))
+						return
+					}
+					w.Write([]byte(`[{"clOrdID":"` + clOrdID + `","ordStatus":"Filled"}]`))
+				}
+			}))
+			defer srv.Close()
+
+			b := NewREST(WithBaseURL(srv.URL), WithRetry(RetryPolicy{
+				MaxAttempts: 3,
+				MinBackoff:  time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+				SettleDelay: 10 * time.Millisecond,
+			}))
+
+			order := NewOrderMarket(XBTUSD, 1)
+			start := time.Now()
+			o, err := b.OrderSend(order)
+
+			Expect(err).To(Succeed())
+			Expect(o.ClOrdID).To(Equal(order.ClOrdID))
+			Expect(o.OrdStatus).To(Equal("Filled"))
+			Expect(posts).To(Equal(2))
+			Expect(gets).To(Equal(2))
+			Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+		})
+
+		It("Should not look up duplicate of order never sent", func() {
+			var gets int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Method == "GET" {
+					gets++
+				}
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"message":"Duplicate clOrdID","name":"HTTPError"}}`))
+			}))
+			defer srv.Close()
+
+			b := NewREST(WithBaseURL(srv.URL), WithRetry(DefaultRetryPolicy))
+
+			_, err := b.OrderSend(NewOrderMarket(XBTUSD, 1))
+			Expect(err).To(BeAssignableToTypeOf(&APIError{}))
+			Expect(err.(*APIError).IsDuplicateClOrdID()).To(BeTrue())
+			Expect(gets).To(BeZero())
+		})
+	})
+
+	Context("Context", func() {
+		It("Should abort request when context expires", func() {
+			release := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				<-release
+			}))
+			defer srv.Close()
+			defer close(release)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := NewREST(WithBaseURL(srv.URL)).OrdersContext(ctx, nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(ctx.Err()).To(Equal(context.DeadlineExceeded))
+		})
+
+		It("Should not close position with cancelled context", func() {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				fmt.Fprint(w, `{"ordStatus":"Filled"}`)
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := NewREST(WithBaseURL(srv.URL)).ClosePositionContext(ctx, XBTUSD, 0)
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(atomic.LoadInt32(&requests)).To(BeZero())
+		})
+
+		It("Should not wait for rate limit past deadline", func() {
+			b := NewREST(WithRateLimit(0, true))
+			b.limiter.update(http.StatusTooManyRequests, http.Header{"Retry-After": []string{"60"}})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			_, err := b.requestContext(ctx, "GET", "/order", nil)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Context("Concurrency", func() {
+		It("Should be safe for concurrent use", func() {
+			var mu sync.Mutex
+			nonces := make(map[string]bool)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mu.Lock()
+				nonces[req.Header.Get("api-nonce")] = true
+				mu.Unlock()
+				w.Write([]byte(`[]`))
+			}))
+			defer srv.Close()
+
+			requests := 0
+			client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				return http.DefaultTransport.RoundTrip(req)
+			})}
+
+			b := NewREST(WithBaseURL(srv.URL), WithLegacyNonce(), WithHTTPClient(client), WithRateLimit(0, true))
+
+			var wg sync.WaitGroup
+			for i := 0; i < 16; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					b.Auth("key", "secret")
+					for j := 0; j < 3; j++ {
+						_, err := b.Orders(nil)
+						Expect(err).To(Succeed())
+					}
+				}()
+			}
+			wg.Wait()
+
+			Expect(requests).To(Equal(48))
+			Expect(nonces).To(HaveLen(48))
+		})
+	})
+
+	Context("Errors", func() {
+		It("Should parse API error", func() {
+			req, err := http.NewRequest("POST", endpoint+apiVersion+"/order", nil)
+			Expect(err).To(Succeed())
+
+			resp := &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Header: http.Header{
+					"X-Ratelimit-Limit":     []string{"300"},
+					"X-Ratelimit-Remaining": []string{"297"},
+					"X-Ratelimit-Reset":     []string{"1508108650"},
+				},
+			}
+			body := []byte(`{"error":{"message":"Account has insufficient Available Balance, 10 XBt required","name":"ValidationError"}}`)
+
+			e := newAPIError(req, resp, body)
+
+			Expect(e.Name).To(Equal("ValidationError"))
+			Expect(e.Method).To(Equal("POST"))
+			Expect(e.Path).To(Equal(apiVersion + "/order"))
+			Expect(e.RateLimit.Limit).To(Equal(300))
+			Expect(e.RateLimit.Remaining).To(Equal(297))
+			Expect(e.RateLimit.Reset.Unix()).To(BeEquivalentTo(1508108650))
+			Expect(e.IsInsufficientBalance()).To(BeTrue())
+			Expect(e.IsRateLimited()).To(BeFalse())
+			Expect(e.IsOverloaded()).To(BeFalse())
+		})
+
+		It("Should keep raw body of non JSON error", func() {
+			req, err := http.NewRequest("GET", endpoint+apiVersion+"/order", nil)
+			Expect(err).To(Succeed())
+
+			resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+			e := newAPIError(req, resp, []byte("<html>overloaded</html>\n"))
+
+			Expect(e.Message).To(Equal("<html>overloaded</html>"))
+			Expect(e.IsOverloaded()).To(BeTrue())
+		})
+	})
 })
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,145 @@
+package bitmex
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// RetryPolicy - retry of OrderSend on 503 "system overloaded" and network
+// errors. Orders get unique ClOrdID, so after ambiguous failure the order is
+// looked up by it before being sent again. Order still in flight can be
+// rejected as duplicate ClOrdID on resend, it is then looked up again.
+// Lookups wait SettleDelay first, so order being placed shows up
+type RetryPolicy struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	SettleDelay time.Duration
+}
+
+// DefaultRetryPolicy - 5 attempts with backoff from 500ms up to 5s
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+	SettleDelay: 250 * time.Millisecond,
+}
+
+// NewClOrdID - random client order id
+func NewClOrdID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// isNetworkError - request might have reached exchange, outcome is unknown
+func isNetworkError(err error) bool {
+	_, ok := err.(*url.Error)
+	return ok
+}
+
+// orderSendRetry - sends order according to retry policy, ClOrdID is
+// assigned to the order if it has none
+func (r *REST) orderSendRetry(ctx context.Context, order *Order) (Order, error) {
+	if order.ClOrdID == "" {
+		order.ClOrdID = NewClOrdID()
+	}
+
+	var o Order
+	var err error
+
+	// earlier attempt might have been placed
+	ambiguous := false
+
+	for attempt := 0; attempt < r.retry.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if sleepErr := sleepContext(ctx, backoff(r.retry.MinBackoff, r.retry.MaxBackoff, attempt-1)); sleepErr != nil {
+				return o, err
+			}
+		}
+
+		o, err = r.orderSend(ctx, order)
+		if err == nil {
+			return o, nil
+		}
+		if ctx.Err() != nil {
+			// Order might have been sent, caller gave up on knowing
+			return o, err
+		}
+
+		if apiErr, ok := err.(*APIError); ok && apiErr.IsOverloaded() {
+			// Overloaded orders are guaranteed to be rejected
+			log.Warnf("Order %s rejected, system overloaded, retrying", order.ClOrdID)
+			continue
+		}
+
+		if apiErr, ok := err.(*APIError); ok && apiErr.IsDuplicateClOrdID() && ambiguous {
+			// Earlier attempt was placed after all
+			log.Warnf("Order %s already placed, looking it up", order.ClOrdID)
+
+			found, lookupErr := r.orderByClOrdID(ctx, order.ClOrdID, attempt)
+			if lookupErr == nil && found != nil {
+				return *found, nil
+			}
+			return o, err
+		}
+
+		if !isNetworkError(err) {
+			return o, err
+		}
+
+		log.Warnf("Order %s outcome unknown: %v", order.ClOrdID, err)
+		ambiguous = true
+
+		found, lookupErr := r.orderByClOrdID(ctx, order.ClOrdID, attempt)
+		if lookupErr != nil {
+			// Resending without knowing the outcome risks double fill
+			return o, err
+		}
+		if found != nil {
+			return *found, nil
+		}
+	}
+
+	return o, err
+}
+
+// orderByClOrdID - looks order up after settle delay, retrying lookup on
+// network errors
+func (r *REST) orderByClOrdID(ctx context.Context, clOrdID string, attempt int) (*Order, error) {
+	q := &Query{
+		Filter:  map[string]interface{}{"clOrdID": clOrdID},
+		Count:   1,
+		Reverse: true,
+	}
+
+	if err := sleepContext(ctx, r.retry.SettleDelay); err != nil {
+		return nil, err
+	}
+
+	var err error
+	for ; attempt < r.retry.MaxAttempts; attempt++ {
+		var orders []Order
+		orders, err = r.OrdersContext(ctx, q)
+		if err == nil {
+			if len(orders) == 0 {
+				return nil, nil
+			}
+			return &orders[0], nil
+		}
+
+		if apiErr, ok := err.(*APIError); !isNetworkError(err) && !(ok && apiErr.IsOverloaded()) {
+			return nil, err
+		}
+		if sleepErr := sleepContext(ctx, backoff(r.retry.MinBackoff, r.retry.MaxBackoff, attempt)); sleepErr != nil {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
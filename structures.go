@@ -29,32 +29,31 @@ type WSQuote struct {
 	AskSize   int64     `json:"askSize"`
 }
 
-//WSPosition - position structure
-type WSPosition struct {
-	Timestamp        time.Time `json:"timestamp"`
-	Symbol           Contract  `json:"symbol"`
-	Account          int64     `json:"account"`
-	CurrentQty       int64     `json:"currentQty"`
-	MarkPrice        float64   `json:"markPrice"`
-	SimpleQty        float64   `json:"simpleQty"`
-	SimplePnl        float64   `json:"simplePnl"`
-	LiquidationPrice float64   `json:"liquidationPrice"`
-}
+//WSPosition - position structure, kept for compatibility with Position
+type WSPosition = Position
 
 type wsData struct {
-	Table       string            `json:"table"`
-	Action      string            `json:"action"`
-	Keys        []string          `json:"keys"`
-	Attributes  map[string]string `json:"attributes"`
-	Types       map[string]string `json:"types"`
-	ForeignKeys map[string]string `json:"foreignKeys"`
+	Table       string                 `json:"table"`
+	Action      string                 `json:"action"`
+	Keys        []string               `json:"keys"`
+	Attributes  map[string]string      `json:"attributes"`
+	Types       map[string]string      `json:"types"`
+	ForeignKeys map[string]string      `json:"foreignKeys"`
+	Filter      map[string]interface{} `json:"filter"`
 	Data        json.RawMessage
 }
 
+// filterSymbol - symbol of per-contract topic the message is limited to
+func (d wsData) filterSymbol() (Contract, bool) {
+	symbol, ok := d.Filter["symbol"].(string)
+	return Contract(symbol), ok && symbol != ""
+}
+
 type wsSuccess struct {
-	Success   bool              `json:"success"`
-	Subscribe string            `json:"subscribe"`
-	Request   map[string]string `json:"request"`
+	Success     bool              `json:"success"`
+	Subscribe   string            `json:"subscribe"`
+	Unsubscribe string            `json:"unsubscribe"`
+	Request     map[string]string `json:"request"`
 }
 
 type wsInfo struct {
@@ -66,5 +65,12 @@ type wsInfo struct {
 }
 
 type wsError struct {
-	Error string `json:"error"`
+	Error   string    `json:"error"`
+	Request wsRequest `json:"request"`
+}
+
+// wsRequest - request echoed by server reply
+type wsRequest struct {
+	Op   string          `json:"op"`
+	Args json.RawMessage `json:"args"`
 }
@@ -0,0 +1,271 @@
+package bitmex
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// defaultKeys - keys of tables in case update arrives before partial
+var defaultKeys = map[string][]string{
+	"order":    {"orderID"},
+	"position": {"account", "symbol", "currency"},
+	"margin":   {"account", "currency"},
+	"wallet":   {"account", "currency"},
+
+	"instrument":  {"symbol"},
+	"liquidation": {"orderID"},
+}
+
+// Table - rows of websocket table kept by keys server sent with partial.
+// Updates carry only changed fields, they are merged into stored rows.
+// Table is safe for concurrent use
+type Table struct {
+	mu   sync.RWMutex
+	name string
+	keys []string
+	rows map[string]map[string]json.RawMessage
+	// row keys in order of arrival
+	order []string
+}
+
+func newTable(name string) *Table {
+	return &Table{
+		name: name,
+		keys: defaultKeys[name],
+		rows: make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// Name - table name
+func (t *Table) Name() string {
+	return t.name
+}
+
+// Len - number of rows
+func (t *Table) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.rows)
+}
+
+// Rows - decodes all rows into slice pointed to by v, oldest first
+func (t *Table) Rows(v interface{}) error {
+	t.mu.RLock()
+	rows := make([]map[string]json.RawMessage, 0, len(t.order))
+	for _, key := range t.order {
+		rows = append(rows, t.rows[key])
+	}
+	data, err := json.Marshal(rows)
+	t.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Get - decodes row with key values (in order of table keys) into v,
+// e.g. Get(&position, 1, "XBTUSD", "XBt"). Returns false if there is none
+func (t *Table) Get(v interface{}, values ...interface{}) (bool, error) {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return false, err
+		}
+		parts[i] = keyPart(raw)
+	}
+
+	t.mu.RLock()
+	row, ok := t.rows[strings.Join(parts, "\x00")]
+	var data []byte
+	var err error
+	if ok {
+		data, err = json.Marshal(row)
+	}
+	t.mu.RUnlock()
+
+	if !ok || err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// keyPart - canonical form of key value, strings without quotes
+func keyPart(raw json.RawMessage) string {
+	var s string
+	if len(raw) > 0 && raw[0] == '"' && json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// key - must be called locked
+func (t *Table) key(row map[string]json.RawMessage) string {
+	parts := make([]string, len(t.keys))
+	for i, name := range t.keys {
+		parts[i] = keyPart(row[name])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// apply - applies message and returns JSON array of affected rows in their
+// full merged form, deleted rows as they were last known
+func (t *Table) apply(msg wsData) json.RawMessage {
+	var rows []map[string]json.RawMessage
+	json.Unmarshal(msg.Data, &rows)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if msg.Action == "partial" {
+		if len(msg.Keys) > 0 {
+			t.keys = msg.Keys
+		}
+		t.reset(msg.Filter)
+	}
+
+	affected := make([]map[string]json.RawMessage, 0, len(rows))
+
+	for _, row := range rows {
+		key := t.key(row)
+		stored, ok := t.rows[key]
+
+		switch msg.Action {
+		case "delete":
+			if !ok {
+				continue
+			}
+			delete(t.rows, key)
+			t.removeOrder(key)
+			affected = append(affected, stored)
+
+		case "update":
+			if ok {
+				for field, value := range row {
+					stored[field] = value
+				}
+				affected = append(affected, stored)
+				continue
+			}
+			fallthrough
+
+		default:
+			if !ok {
+				t.order = append(t.order, key)
+			}
+			t.rows[key] = row
+			affected = append(affected, row)
+		}
+	}
+
+	data, _ := json.Marshal(affected)
+	return data
+}
+
+// reset - drops rows matching partial filter, all rows if there is none.
+// Must be called locked
+func (t *Table) reset(filter map[string]interface{}) {
+	if len(filter) == 0 {
+		t.rows = make(map[string]map[string]json.RawMessage)
+		t.order = nil
+		return
+	}
+
+	parts := make(map[string]string, len(filter))
+	for field, value := range filter {
+		raw, _ := json.Marshal(value)
+		parts[field] = keyPart(raw)
+	}
+
+	order := t.order[:0:0]
+	for _, key := range t.order {
+		row := t.rows[key]
+		matches := true
+		for field, part := range parts {
+			if keyPart(row[field]) != part {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			delete(t.rows, key)
+		} else {
+			order = append(order, key)
+		}
+	}
+	t.order = order
+}
+
+// removeOrder - must be called locked
+func (t *Table) removeOrder(key string) {
+	for i, one := range t.order {
+		if one == key {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// Table - current content of websocket table (order, position, margin,
+// wallet, instrument, liquidation), nil until first message of the table
+// arrives. Content is stale after reconnection until server sends new partial
+func (ws *WS) Table(name string) *Table {
+	ws.Lock()
+	defer ws.Unlock()
+	return ws.tables[name]
+}
+
+// applyTable - stores message, returns affected rows merged with stored ones
+func (ws *WS) applyTable(msg wsData) json.RawMessage {
+	ws.Lock()
+	t, ok := ws.tables[msg.Table]
+	if !ok {
+		t = newTable(msg.Table)
+		ws.tables[msg.Table] = t
+	}
+	ws.Unlock()
+
+	return t.apply(msg)
+}
+
+// Orders - all orders of order table
+func (ws *WS) Orders() []Order {
+	var orders []Order
+	if t := ws.Table("order"); t != nil {
+		t.Rows(&orders)
+	}
+	return orders
+}
+
+// OpenOrders - orders of order table which are new or partially filled
+func (ws *WS) OpenOrders() []Order {
+	var open []Order
+	for _, one := range ws.Orders() {
+		if one.OrdStatus == "New" || one.OrdStatus == "PartiallyFilled" {
+			open = append(open, one)
+		}
+	}
+	return open
+}
+
+// Positions - all positions of position table
+func (ws *WS) Positions() []Position {
+	var positions []Position
+	if t := ws.Table("position"); t != nil {
+		t.Rows(&positions)
+	}
+	return positions
+}
+
+// Position - current position in symbol
+func (ws *WS) Position(symbol Contract) (Position, bool) {
+	for _, one := range ws.Positions() {
+		if one.Symbol == symbol {
+			return one, true
+		}
+	}
+	return Position{}, false
+}
@@ -0,0 +1,63 @@
+package bitmex
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Table", func() {
+	It("Should apply actions by keys", func() {
+		t := newTable("order")
+
+		t.apply(wsData{Action: "partial", Keys: []string{"orderID"}, Data: []byte(`[
+			{"orderID":"00000000-0000-0000-0000-00000000000a","symbol":"XBTUSD","price":6500,"ordStatus":"New"},
+			{"orderID":"00000000-0000-0000-0000-00000000000b","symbol":"XBTUSD","price":6600,"ordStatus":"New"}
+		]`)})
+		t.apply(wsData{Action: "insert", Data: []byte(`[{"orderID":"00000000-0000-0000-0000-00000000000c","symbol":"ETHUSD","price":300,"ordStatus":"New"}]`)})
+		t.apply(wsData{Action: "update", Data: []byte(`[{"orderID":"00000000-0000-0000-0000-00000000000a","ordStatus":"Filled"}]`)})
+		deleted := t.apply(wsData{Action: "delete", Data: []byte(`[{"orderID":"00000000-0000-0000-0000-00000000000b"}]`)})
+
+		Expect(string(deleted)).To(ContainSubstring(`"price":6600`))
+
+		var orders []Order
+		Expect(t.Rows(&orders)).To(Succeed())
+		Expect(orders).To(HaveLen(2))
+		Expect(orders[0].OrdStatus).To(Equal("Filled"))
+		Expect(orders[0].Price).To(Equal(6500.0))
+		Expect(orders[1].Symbol).To(Equal(Contract("ETHUSD")))
+
+		var o Order
+		found, err := t.Get(&o, "00000000-0000-0000-0000-00000000000c")
+		Expect(err).To(Succeed())
+		Expect(found).To(BeTrue())
+		Expect(o.Price).To(Equal(300.0))
+
+		t.apply(wsData{Action: "partial", Keys: []string{"orderID"}, Data: []byte(`[]`)})
+		Expect(t.Len()).To(BeZero())
+	})
+
+	It("Should forward merged position updates", func() {
+		ws := NewWS()
+		positions := make(chan Position, 2)
+		Expect(ws.SubPosition(positions, nil)).NotTo(BeNil())
+
+		ws.dispatch(`{"table":"position","action":"partial","keys":["account","symbol","currency"],"data":[
+			{"account":1,"symbol":"XBTUSD","currency":"XBt","currentQty":100,"avgEntryPrice":6500,"leverage":10}
+		]}`)
+		ws.dispatch(`{"table":"position","action":"update","data":[
+			{"account":1,"symbol":"XBTUSD","currency":"XBt","markPrice":6600}
+		]}`)
+
+		var p Position
+		Expect(positions).To(Receive())
+		Expect(positions).To(Receive(&p))
+		Expect(p.CurrentQty).To(BeEquivalentTo(100))
+		Expect(p.AvgEntryPrice).To(Equal(6500.0))
+		Expect(p.MarkPrice).To(Equal(6600.0))
+
+		current, ok := ws.Position(XBTUSD)
+		Expect(ok).To(BeTrue())
+		Expect(current).To(Equal(p))
+		Expect(ws.OpenOrders()).To(BeEmpty())
+	})
+})
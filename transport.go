@@ -0,0 +1,75 @@
+package bitmex
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Conn - websocket connection used by WS. Implementations wrap websocket
+// libraries or in-memory pipes, Close has to unblock pending ReadMessage
+type Conn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(msg []byte) error
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+// Dialer - opens websocket connections for WS, e.g. adapter of gorilla or
+// nhooyr websocket with compression and read limits configured
+type Dialer interface {
+	Dial(ctx context.Context, url string) (Conn, error)
+}
+
+// DialerFunc - function implementing Dialer
+type DialerFunc func(ctx context.Context, url string) (Conn, error)
+
+// Dial - calls f
+func (f DialerFunc) Dial(ctx context.Context, url string) (Conn, error) {
+	return f(ctx, url)
+}
+
+// XNetDialer - default Dialer using golang.org/x/net/websocket, which
+// supports neither control frames nor compression
+type XNetDialer struct {
+	// Origin header, defaults to http://localhost/
+	Origin string
+	// MaxPayloadBytes - limit of received message, zero keeps library default
+	MaxPayloadBytes int
+}
+
+// Dial - connects to url, ctx is honored only before dialing starts
+func (d *XNetDialer) Dial(ctx context.Context, url string) (Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	origin := d.Origin
+	if origin == "" {
+		origin = "http://localhost/"
+	}
+
+	conn, err := websocket.Dial(url, "", origin)
+	if err != nil {
+		return nil, err
+	}
+	conn.MaxPayloadBytes = d.MaxPayloadBytes
+
+	return &xnetConn{conn}, nil
+}
+
+type xnetConn struct {
+	*websocket.Conn
+}
+
+func (c *xnetConn) ReadMessage() ([]byte, error) {
+	var msg []byte
+	err := websocket.Message.Receive(c.Conn, &msg)
+	return msg, err
+}
+
+func (c *xnetConn) WriteMessage(msg []byte) error {
+	_, err := c.Conn.Write(msg)
+	return err
+}
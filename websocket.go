@@ -3,52 +3,127 @@ package bitmex
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apex/log"
-
-	"golang.org/x/net/websocket"
 )
 
 const wsURL = "wss://www.bitmex.com/realtime"
 
+// ErrNotConnected - message can't be sent before Connect
+var ErrNotConnected = errors.New("WS not connected")
+
 //WS - websocket connection object
 type WS struct {
+	// accessed atomically, kept first for 64-bit alignment
+	lastRecv, pingSent, latency int64
+	nonce                       int64
+	reconnecting                int32
+
 	sync.Mutex
-	conn   *websocket.Conn
+	conn   Conn
+	url    string
 	log    *log.Logger
-	nonce  int64
 	key    string
 	secret string
 	chSucc map[string][]chan struct{}
+	chFail map[string][]chan error
 	quit   chan struct{}
+	events chan Event
+	errors chan error
+
+	// connection outstanding ping was sent on
+	pingConn Conn
+
+	// subscribed topics, replayed after reconnect
+	topics map[string]struct{}
+
+	// topics sent on current connection, true once server confirmed them
+	subscribed map[string]bool
+
+	reconnectMin, reconnectMax time.Duration
+	pingInterval, pongTimeout  time.Duration
+
+	// api-expires validity, zero for legacy nonce authentication
+	expires time.Duration
 
 	// channels subscribed to different contracts
 
 	chTrade    map[chan WSTrade][]Contract
 	chQuote    map[chan WSQuote][]Contract
 	chOrder    map[chan Order][]Contract
-	chPosition map[chan WSPosition][]Contract
+	chPosition map[chan Position][]Contract
+	chBook     map[chan *OrderBook][]Contract
+	chMargin   map[chan Margin]struct{}
+	chWallet   map[chan Wallet]struct{}
+	chExec     map[chan Execution][]Contract
+
+	chInstrument map[chan Instrument][]Contract
+	chFunding    map[chan Funding][]Contract
+	chLiq        map[chan Liquidation][]Contract
+	chSettlement map[chan Settlement][]Contract
+	chInsurance  map[chan Insurance]struct{}
+
+	// candle channels by interval
+	chBin map[BinSize]map[chan Candle][]Contract
+
+	books       map[Contract]*OrderBook
+	instruments *Instruments
+
+	recorder *Recorder
+	dialer   Dialer
+
+	// keyed tables by name
+	tables map[string]*Table
 }
 
 //NewWS - creates new websocket object
-func NewWS() *WS {
+func NewWS(opts ...Option) *WS {
+	cfg := newConfig(opts)
+
 	return &WS{
-		nonce:      time.Now().UnixNano() / int64(time.Millisecond),
-		quit:       make(chan struct{}),
-		chTrade:    make(map[chan WSTrade][]Contract, 0),
-		chQuote:    make(map[chan WSQuote][]Contract, 0),
-		chOrder:    make(map[chan Order][]Contract, 0),
-		chPosition: make(map[chan WSPosition][]Contract, 0),
-		chSucc:     make(map[string][]chan struct{}, 0),
+		url:          cfg.wsURL,
+		nonce:        time.Now().UnixNano() / int64(time.Millisecond),
+		expires:      cfg.expires,
+		recorder:     cfg.recorder,
+		dialer:       cfg.dialer,
+		quit:         make(chan struct{}),
+		events:       make(chan Event, 16),
+		errors:       make(chan error, 16),
+		topics:       make(map[string]struct{}, 0),
+		subscribed:   make(map[string]bool, 0),
+		reconnectMin: cfg.reconnectMin,
+		reconnectMax: cfg.reconnectMax,
+		pingInterval: cfg.pingInterval,
+		pongTimeout:  cfg.pongTimeout,
+		chTrade:      make(map[chan WSTrade][]Contract, 0),
+		chQuote:      make(map[chan WSQuote][]Contract, 0),
+		chOrder:      make(map[chan Order][]Contract, 0),
+		chPosition:   make(map[chan Position][]Contract, 0),
+		chBook:       make(map[chan *OrderBook][]Contract, 0),
+		chMargin:     make(map[chan Margin]struct{}, 0),
+		chWallet:     make(map[chan Wallet]struct{}, 0),
+		chExec:       make(map[chan Execution][]Contract, 0),
+		chInstrument: make(map[chan Instrument][]Contract, 0),
+		chFunding:    make(map[chan Funding][]Contract, 0),
+		chLiq:        make(map[chan Liquidation][]Contract, 0),
+		chSettlement: make(map[chan Settlement][]Contract, 0),
+		chInsurance:  make(map[chan Insurance]struct{}, 0),
+		chBin:        make(map[BinSize]map[chan Candle][]Contract, 0),
+		chSucc:       make(map[string][]chan struct{}, 0),
+		chFail:       make(map[string][]chan error, 0),
+		books:        make(map[Contract]*OrderBook, 0),
+		tables:       make(map[string]*Table, 0),
 	}
 }
 
 //Connect - connects
 func (ws *WS) Connect() error {
-	conn, err := websocket.Dial(wsURL, "", "http://localhost/")
+	conn, err := ws.dial()
 
 	if err != nil {
 		return err
@@ -56,9 +131,35 @@ func (ws *WS) Connect() error {
 
 	log.Info("Connected")
 
+	ws.Lock()
 	ws.conn = conn
+	ws.subscribed = make(map[string]bool, 0)
+	authenticated := ws.key != ""
+	var topics []string
+	for topic := range ws.topics {
+		topics = append(topics, topic)
+	}
+	ws.Unlock()
+
+	atomic.StoreInt64(&ws.lastRecv, time.Now().UnixNano())
+	ws.event(StateConnected, nil)
 
 	go ws.read()
+	go ws.heartbeat()
+
+	// Authentication and subscriptions made before connecting, server handles
+	// requests in order so private topics follow credentials
+	if authenticated {
+		if err := ws.send(ws.authMessage()); err != nil {
+			return err
+		}
+	}
+
+	for _, topic := range topics {
+		if err := ws.sendSubscribe(topic); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -66,108 +167,221 @@ func (ws *WS) Connect() error {
 //Disconnect - Disconnects from websocket
 func (ws *WS) Disconnect() {
 	log.Info("Disconnecting")
+	ws.Lock()
 	close(ws.quit)
-	ws.conn.Close()
+	if ws.conn != nil {
+		ws.conn.Close()
+	}
+	ws.unsubscribedAll()
+	ws.Unlock()
+
+	ws.event(StateDisconnected, nil)
 	//TODO Close all channels
 	return
 }
 
 func (ws *WS) read() {
 	for {
-		// TODO []byte
-		var msg string
+		ws.Lock()
+		conn := ws.conn
+		ws.Unlock()
 
-		err := websocket.Message.Receive(ws.conn, &msg)
+		if ws.pingInterval > 0 && ws.pongTimeout > 0 {
+			// Backstop for heartbeat, connection is silent for too long
+			conn.SetReadDeadline(time.Now().Add(ws.pingInterval + 2*ws.pongTimeout))
+		}
+
+		raw, err := conn.ReadMessage()
 		if err != nil {
 			select {
 			case <-ws.quit:
 				return
 			default:
-				log.Fatalf("WS error: %v", err)
 			}
+
+			if !ws.reconnect(err) {
+				return
+			}
+			continue
 		}
 
+		msg := string(raw)
 		log.Debugf("Raw: %v", msg)
+		ws.received(msg)
+
+		if ws.recorder != nil {
+			if err := ws.recorder.Record(time.Now(), msg); err != nil {
+				ws.error(err)
+			}
+		}
+
+		ws.dispatch(msg)
+	}
+}
+
+// dispatch - handles one message from server, live or replayed
+func (ws *WS) dispatch(msg string) {
+	switch {
+	case msg == "pong":
+		// Heartbeat, handled by received
+
+	case strings.HasPrefix(msg, `{"success"`):
+		var success wsSuccess
+		json.Unmarshal([]byte(msg), &success)
+		log.Debugf("Success: %#v", success)
+
+		if success.Unsubscribe != "" {
+			ws.unsubscribed(success.Unsubscribe)
+			break
+		}
+
+		ws.Lock()
+		if success.Subscribe != "" {
+			ws.subscribed[success.Subscribe] = true
+		}
 
-		switch {
-		case strings.HasPrefix(msg, `{"success"`):
-			var success wsSuccess
-			json.Unmarshal([]byte(msg), &success)
-			log.Debugf("Success: %#v", success)
-
-			if channels, found := ws.chSucc[success.Request["op"]]; found {
-				for _, ch := range channels {
-					select {
-					case ch <- struct{}{}:
-					default:
-					}
+		if channels, found := ws.chSucc[success.Request["op"]]; found {
+			for _, ch := range channels {
+				select {
+				case ch <- struct{}{}:
+				default:
 				}
 			}
+		}
 
-			if channels, found := ws.chSucc[success.Request["args"]]; found {
-				for _, ch := range channels {
-					select {
-					case ch <- struct{}{}:
-					default:
-					}
+		if channels, found := ws.chSucc[success.Request["args"]]; found {
+			for _, ch := range channels {
+				select {
+				case ch <- struct{}{}:
+				default:
 				}
 			}
+		}
+		ws.Unlock()
 
-		case strings.HasPrefix(msg, `{"info"`):
-			var info wsInfo
-			json.Unmarshal([]byte(msg), &info)
-			log.Infof("Info: %v", info)
+	case strings.HasPrefix(msg, `{"now"`):
+		// cancelAllAfter is confirmed by its cancel time instead of success
+		log.Debugf("Cancel all after: %s", msg)
 
-		case strings.Contains(msg, `{"table"`):
-			var table wsData
-			json.Unmarshal([]byte(msg), &table)
-			log.Debugf("Table: %#v", table)
+		ws.Lock()
+		for _, ch := range ws.chSucc["cancelAllAfter"] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+		ws.Unlock()
+
+	case strings.HasPrefix(msg, `{"info"`):
+		var info wsInfo
+		json.Unmarshal([]byte(msg), &info)
+		log.Infof("Info: %v", info)
+
+	case strings.Contains(msg, `{"table"`):
+		var table wsData
+		if err := json.Unmarshal([]byte(msg), &table); err != nil {
+			ws.error(fmt.Errorf("Malformed WS message: %v: %s", err, msg))
+			break
+		}
+		log.Debugf("Table: %#v", table)
 
-			switch table.Table {
+		switch table.Table {
 
-			case "trade":
-				var trades []WSTrade
-				json.Unmarshal(table.Data, &trades)
+		case "trade":
+			var trades []WSTrade
+			json.Unmarshal(table.Data, &trades)
 
-				log.Debugf("Trades: %#v", trades)
+			log.Debugf("Trades: %#v", trades)
 
-				for _, one := range trades {
-					ws.trade(one)
-				}
+			for _, one := range trades {
+				ws.trade(one)
+			}
 
-			case "quote":
-				var quotes []WSQuote
-				json.Unmarshal(table.Data, &quotes)
+		case "quote":
+			var quotes []WSQuote
+			json.Unmarshal(table.Data, &quotes)
 
-				log.Debugf("Quotes: %#v", quotes)
+			log.Debugf("Quotes: %#v", quotes)
 
-				for _, one := range quotes {
-					ws.quote(one)
-				}
+			for _, one := range quotes {
+				ws.quote(one)
+			}
 
-			case "order":
-				var orders []Order
-				json.Unmarshal(table.Data, &orders)
+		case "order":
+			var orders []Order
+			json.Unmarshal(ws.applyTable(table), &orders)
 
-				log.Debugf("Orders: %#v", orders)
+			log.Debugf("Orders: %#v", orders)
 
-				for _, one := range orders {
-					ws.order(one)
-				}
+			for _, one := range orders {
+				ws.order(one)
+			}
 
-			case "position":
-				var positions []WSPosition
-				json.Unmarshal(table.Data, &positions)
+		case "position":
+			var positions []Position
+			json.Unmarshal(ws.applyTable(table), &positions)
 
-				log.Debugf("Positions: %#v", positions)
+			log.Debugf("Positions: %#v", positions)
 
-				for _, one := range positions {
-					ws.position(one)
-				}
+			for _, one := range positions {
+				ws.position(one)
 			}
-		default:
-			ws.fatal(errors.New("Unknown WS message"))
+
+		case "instrument":
+			ws.Lock()
+			instruments := ws.instruments
+			ws.Unlock()
+
+			if instruments != nil {
+				instruments.apply(table)
+			}
+
+			ws.stream(table)
+
+		case "funding", "liquidation", "settlement", "insurance",
+			"tradeBin1m", "tradeBin5m", "tradeBin1h", "tradeBin1d":
+			ws.stream(table)
+
+		case "execution":
+			var executions []Execution
+			json.Unmarshal(table.Data, &executions)
+
+			log.Debugf("Executions: %#v", executions)
+
+			for _, one := range executions {
+				ws.execution(one)
+			}
+
+		case "margin":
+			var margins []Margin
+			json.Unmarshal(ws.applyTable(table), &margins)
+
+			for _, one := range margins {
+				ws.margin(one)
+			}
+
+		case "wallet":
+			var wallets []Wallet
+			json.Unmarshal(ws.applyTable(table), &wallets)
+
+			for _, one := range wallets {
+				ws.wallet(one)
+			}
+
+		case OrderBookL2, OrderBookL225:
+			ws.orderBook(table)
 		}
+	case strings.HasPrefix(msg, `{"status"`), strings.HasPrefix(msg, `{"error"`):
+		var wsErr wsError
+		json.Unmarshal([]byte(msg), &wsErr)
+
+		err := fmt.Errorf("WS error: %s", wsErr.Error)
+		if !ws.fail(wsErr.Request, err) {
+			ws.error(err)
+		}
+
+	default:
+		ws.error(fmt.Errorf("Unknown WS message: %s", msg))
 	}
 }
 
@@ -198,7 +412,7 @@ func (ws *WS) sendQuote(ch chan WSQuote, quote WSQuote) {
 	}
 }
 
-func (ws *WS) sendPosition(ch chan WSPosition, position WSPosition) {
+func (ws *WS) sendPosition(ch chan Position, position Position) {
 	select {
 	case ch <- position:
 		log.Debugf("Position sent: %#v - %#v", ch, position)
@@ -208,6 +422,9 @@ func (ws *WS) sendPosition(ch chan WSPosition, position WSPosition) {
 }
 
 func (ws *WS) trade(trade WSTrade) {
+	ws.Lock()
+	defer ws.Unlock()
+
 	for ch, symbols := range ws.chTrade {
 		// All
 		if len(symbols) == 0 {
@@ -225,6 +442,9 @@ func (ws *WS) trade(trade WSTrade) {
 }
 
 func (ws *WS) order(order Order) {
+	ws.Lock()
+	defer ws.Unlock()
+
 	for ch, symbols := range ws.chOrder {
 		// All
 		if len(symbols) == 0 {
@@ -241,7 +461,10 @@ func (ws *WS) order(order Order) {
 	}
 }
 
-func (ws *WS) position(position WSPosition) {
+func (ws *WS) position(position Position) {
+	ws.Lock()
+	defer ws.Unlock()
+
 	for ch, symbols := range ws.chPosition {
 		// All
 		if len(symbols) == 0 {
@@ -259,6 +482,9 @@ func (ws *WS) position(position WSPosition) {
 }
 
 func (ws *WS) quote(quote WSQuote) {
+	ws.Lock()
+	defer ws.Unlock()
+
 	for ch, symbols := range ws.chQuote {
 		// All
 		if len(symbols) == 0 {
@@ -276,24 +502,80 @@ func (ws *WS) quote(quote WSQuote) {
 }
 
 //Writing to WS
-func (ws *WS) send(msg string) {
+func (ws *WS) send(msg string) error {
 	defer ws.Unlock()
 
 	log.Debugf("Writing WS: %#v", string(msg))
 	ws.Lock()
 
-	if _, err := ws.conn.Write([]byte(msg)); err != nil {
-		ws.fatal(err)
+	if ws.conn == nil {
+		return ErrNotConnected
+	}
+
+	if err := ws.conn.WriteMessage([]byte(msg)); err != nil {
+		return fmt.Errorf("WS write: %v", err)
+	}
+	return nil
+}
+
+// subscribe - sends subscription and remembers topic for replay after reconnect,
+// so failed send is recovered on reconnection
+func (ws *WS) subscribe(topic string) error {
+	ws.Lock()
+	ws.topics[topic] = struct{}{}
+	ws.Unlock()
+
+	err := ws.sendSubscribe(topic)
+	if err == ErrNotConnected {
+		// Sent by Connect, or never when only replaying
+		return nil
+	}
+	return err
+}
+
+// sendSubscribe - sends subscription unless topic was already sent on current
+// connection, waiters then share confirmation of the first request
+func (ws *WS) sendSubscribe(topic string) error {
+	ws.Lock()
+	if _, sent := ws.subscribed[topic]; sent {
+		ws.Unlock()
+		return nil
+	}
+	if ws.conn != nil {
+		ws.subscribed[topic] = false
+	}
+	ws.Unlock()
+
+	err := ws.send(`{"op": "subscribe", "args": "` + topic + `"}`)
+	if err != nil {
+		ws.Lock()
+		if confirmed, sent := ws.subscribed[topic]; sent && !confirmed {
+			delete(ws.subscribed, topic)
+		}
+		ws.Unlock()
 	}
+	return err
 }
 
-func (ws *WS) fatal(err error) {
-	ws.Disconnect()
-	log.Fatalf("%v", err.Error())
+//Errors - channel of asynchronous errors: unknown or error messages from
+//server, failed reconnection attempts and writes without caller to return
+//them to. Errors are dropped if nobody reads them
+func (ws *WS) Errors() <-chan error {
+	return ws.errors
+}
+
+func (ws *WS) error(err error) {
+	log.Warnf("%v", err)
+
+	select {
+	case ws.errors <- err:
+	default:
+		log.Debugf("Error channel busy, dropped: %v", err)
+	}
 }
 
 //SubTrade - subscribes channel to trades
-func (ws *WS) SubTrade(ch chan WSTrade, contract []Contract) {
+func (ws *WS) SubTrade(ch chan WSTrade, contract []Contract) error {
 	ws.Lock()
 
 	if _, ok := ws.chTrade[ch]; !ok {
@@ -305,12 +587,15 @@ func (ws *WS) SubTrade(ch chan WSTrade, contract []Contract) {
 	ws.Unlock()
 
 	for _, one := range contract {
-		ws.send(`{"op": "subscribe", "args": "trade:` + string(one) + `"}`)
+		if err := ws.subscribe("trade:" + string(one)); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 //SubQuote - subscribes to quotes
-func (ws *WS) SubQuote(ch chan WSQuote, contract []Contract) {
+func (ws *WS) SubQuote(ch chan WSQuote, contract []Contract) error {
 
 	ws.Lock()
 
@@ -323,6 +608,9 @@ func (ws *WS) SubQuote(ch chan WSQuote, contract []Contract) {
 	ws.Unlock()
 
 	for _, one := range contract {
-		ws.send(`{"op": "subscribe", "args": "quote:` + string(one) + `"}`)
+		if err := ws.subscribe("quote:" + string(one)); err != nil {
+			return err
+		}
 	}
+	return nil
 }
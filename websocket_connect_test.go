@@ -0,0 +1,63 @@
+package bitmex_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("Connect", func() {
+	var srv *bitmextest.Server
+	var ws *bitmex.WS
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		srv = bitmextest.NewServer("key", "secret")
+		ws = bitmex.NewWS(bitmex.WithDialer(srv.Dialer()), bitmex.WithHeartbeat(0, 0))
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	})
+
+	AfterEach(func() {
+		cancel()
+		srv.Close()
+	})
+
+	It("Should fail fast when not connected", func() {
+		start := time.Now()
+		Expect(ws.AuthContext(ctx, "key", "secret")).To(Equal(bitmex.ErrNotConnected))
+		Expect(ws.SubPositionContext(ctx, make(chan bitmex.Position), nil)).To(Equal(bitmex.ErrNotConnected))
+		Expect(ws.SubTradeContext(ctx, make(chan bitmex.WSTrade), []bitmex.Contract{bitmex.XBTUSD})).To(Equal(bitmex.ErrNotConnected))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+	})
+
+	It("Should send deferred authentication before subscriptions", func() {
+		positions := make(chan bitmex.Position, 1)
+		trades := make(chan bitmex.WSTrade, 1)
+
+		Expect(ws.AuthContext(ctx, "key", "secret")).To(Equal(bitmex.ErrNotConnected))
+		ws.SubPosition(positions, nil)
+		Expect(ws.SubTradeContext(ctx, trades, []bitmex.Contract{bitmex.XBTUSD})).To(Equal(bitmex.ErrNotConnected))
+
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		// Private topic is rejected unless credentials came first
+		Eventually(func() bool { return srv.Subscribed("position") }).Should(BeTrue())
+		Eventually(func() bool { return srv.Subscribed("trade:XBTUSD") }).Should(BeTrue())
+		Consistently(ws.Errors(), 100*time.Millisecond).ShouldNot(Receive())
+
+		srv.SetPosition(bitmex.Position{Symbol: bitmex.XBTUSD, CurrentQty: 100})
+		var p bitmex.Position
+		Eventually(positions).Should(Receive(&p))
+		Expect(p.CurrentQty).To(BeEquivalentTo(100))
+
+		// Confirmed on this connection, not sent again
+		Expect(ws.SubTradeContext(ctx, trades, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+		Consistently(ws.Errors(), 100*time.Millisecond).ShouldNot(Receive())
+	})
+})
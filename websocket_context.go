@@ -0,0 +1,241 @@
+package bitmex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// await - registers waiter for reply to key (op or topic), sends request and
+// blocks until server confirms or rejects it, or ctx is done
+func (ws *WS) await(ctx context.Context, key string, send func() error) error {
+	ch := make(chan struct{}, 1)
+	fail := make(chan error, 1)
+
+	ws.Lock()
+	ws.chSucc[key] = append(ws.chSucc[key], ch)
+	ws.chFail[key] = append(ws.chFail[key], fail)
+	ws.Unlock()
+
+	defer ws.removeWaiter(key, ch, fail)
+
+	if err := send(); err != nil {
+		return err
+	}
+
+	select {
+	case <-ch:
+		return nil
+	case err := <-fail:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ws *WS) removeWaiter(key string, ch chan struct{}, fail chan error) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	dropWaiter(ws.chSucc, key, ch)
+
+	failures := ws.chFail[key]
+	for i, one := range failures {
+		if one == fail {
+			ws.chFail[key] = append(failures[:i:i], failures[i+1:]...)
+			break
+		}
+	}
+
+	if len(ws.chFail[key]) == 0 {
+		delete(ws.chFail, key)
+	}
+}
+
+// dropWaiter - removes ch from waiters of key, must be called locked
+func dropWaiter(waiters map[string][]chan struct{}, key string, ch chan struct{}) {
+	channels := waiters[key]
+	for i, one := range channels {
+		if one == ch {
+			waiters[key] = append(channels[:i:i], channels[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiters[key]) == 0 {
+		delete(waiters, key)
+	}
+}
+
+// errConfirmed - request is not sent, server confirmed it before
+var errConfirmed = errors.New("already confirmed")
+
+// fail - passes error reply to waiters of its request op and topic, false if
+// nobody waits for it
+func (ws *WS) fail(req wsRequest, err error) bool {
+	keys := []string{req.Op}
+
+	var topic string
+	if json.Unmarshal(req.Args, &topic) == nil && topic != "" {
+		keys = append(keys, topic)
+	}
+
+	// Topic alone is key of subscription waiters
+	if req.Op == "unsubscribe" {
+		keys = []string{unsubKey(topic)}
+	}
+
+	ws.Lock()
+	defer ws.Unlock()
+
+	// Rejected subscription may be requested again
+	if req.Op == "subscribe" && topic != "" {
+		delete(ws.subscribed, topic)
+	}
+
+	found := false
+	for _, key := range keys {
+		for _, ch := range ws.chFail[key] {
+			found = true
+			select {
+			case ch <- err:
+			default:
+			}
+		}
+	}
+	return found
+}
+
+// WaitContext - waits for channel returned by Auth, Sub* or Unsub* methods,
+// returns ctx error if it is done first
+func WaitContext(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AuthContext - authenticates and waits for server to accept credentials
+func (ws *WS) AuthContext(ctx context.Context, key, secret string) error {
+	ws.Lock()
+	ws.key = key
+	ws.secret = secret
+	ws.Unlock()
+
+	return ws.await(ctx, ws.authOp(), func() error {
+		return ws.send(ws.authMessage())
+	})
+}
+
+// subscribeContext - subscribes to topic and waits for confirmation, topic
+// stays subscribed when ctx is done first. Topic already confirmed on current
+// connection returns right away. When not connected ErrNotConnected is
+// returned and topic is subscribed by Connect
+func (ws *WS) subscribeContext(ctx context.Context, topic string) error {
+	err := ws.await(ctx, topic, func() error {
+		ws.Lock()
+		ws.topics[topic] = struct{}{}
+		confirmed := ws.subscribed[topic]
+		ws.Unlock()
+
+		if confirmed {
+			return errConfirmed
+		}
+		return ws.sendSubscribe(topic)
+	})
+	if err == errConfirmed {
+		return nil
+	}
+	return err
+}
+
+// subscribeAll - subscribes to topic of every contract, waiting for each
+func (ws *WS) subscribeAll(ctx context.Context, table string, contracts []Contract) error {
+	for _, one := range contracts {
+		if err := ws.subscribeContext(ctx, table+":"+string(one)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubTradeContext - SubTrade waiting for confirmation of every contract
+func (ws *WS) SubTradeContext(ctx context.Context, ch chan WSTrade, contracts []Contract) error {
+	ws.Lock()
+	ws.chTrade[ch] = append(ws.chTrade[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subscribeAll(ctx, "trade", contracts)
+}
+
+// SubQuoteContext - SubQuote waiting for confirmation of every contract
+func (ws *WS) SubQuoteContext(ctx context.Context, ch chan WSQuote, contracts []Contract) error {
+	ws.Lock()
+	ws.chQuote[ch] = append(ws.chQuote[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subscribeAll(ctx, "quote", contracts)
+}
+
+// SubOrderBookContext - SubOrderBook waiting for confirmation of every contract
+func (ws *WS) SubOrderBookContext(ctx context.Context, ch chan *OrderBook, table string, contracts []Contract) error {
+	ws.Lock()
+	if ch != nil {
+		ws.chBook[ch] = append(ws.chBook[ch], contracts...)
+	}
+	for _, one := range contracts {
+		if _, ok := ws.books[one]; !ok {
+			ws.books[one] = NewOrderBook(one)
+		}
+	}
+	ws.Unlock()
+
+	return ws.subscribeAll(ctx, table, contracts)
+}
+
+// SubOrderContext - SubOrder waiting for confirmation
+func (ws *WS) SubOrderContext(ctx context.Context, ch chan Order, contracts []Contract) error {
+	ws.Lock()
+	ws.chOrder[ch] = append(ws.chOrder[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subscribeContext(ctx, "order")
+}
+
+// SubPositionContext - SubPosition waiting for confirmation
+func (ws *WS) SubPositionContext(ctx context.Context, ch chan Position, contracts []Contract) error {
+	ws.Lock()
+	ws.chPosition[ch] = append(ws.chPosition[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subscribeContext(ctx, "position")
+}
+
+// SubExecutionContext - SubExecution waiting for confirmation
+func (ws *WS) SubExecutionContext(ctx context.Context, ch chan Execution, contracts []Contract) error {
+	ws.Lock()
+	ws.chExec[ch] = append(ws.chExec[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subscribeContext(ctx, "execution")
+}
+
+// SubMarginContext - SubMargin waiting for confirmation
+func (ws *WS) SubMarginContext(ctx context.Context, ch chan Margin) error {
+	ws.Lock()
+	ws.chMargin[ch] = struct{}{}
+	ws.Unlock()
+
+	return ws.subscribeContext(ctx, "margin")
+}
+
+// SubWalletContext - SubWallet waiting for confirmation
+func (ws *WS) SubWalletContext(ctx context.Context, ch chan Wallet) error {
+	ws.Lock()
+	ws.chWallet[ch] = struct{}{}
+	ws.Unlock()
+
+	return ws.subscribeContext(ctx, "wallet")
+}
@@ -0,0 +1,93 @@
+package bitmex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/websocket"
+)
+
+var _ = Describe("WebsocketContext", func() {
+	var srv *httptest.Server
+	var ws *WS
+	var subscribes int32
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&subscribes, 0)
+
+		// Confirms everything except position subscription
+		srv = httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+			for {
+				var msg string
+				if err := websocket.Message.Receive(conn, &msg); err != nil {
+					return
+				}
+
+				var req struct {
+					Op   string          `json:"op"`
+					Args json.RawMessage `json:"args"`
+				}
+				json.Unmarshal([]byte(msg), &req)
+				if req.Op == "subscribe" {
+					atomic.AddInt32(&subscribes, 1)
+				}
+
+				var args string
+				json.Unmarshal(req.Args, &args)
+				if args == "position" {
+					continue
+				}
+
+				resp := `{"success":true,"request":{"op":"` + req.Op + `","args":"` + args + `"}}`
+				if req.Op == "subscribe" {
+					resp = `{"success":true,"subscribe":"` + args + `","request":{"op":"` + req.Op + `","args":"` + args + `"}}`
+				}
+				websocket.Message.Send(conn, resp)
+			}
+		}))
+
+		ws = NewWS(WithBaseURL(srv.URL), WithHeartbeat(0, 0))
+		Expect(ws.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ws.Disconnect()
+		srv.Close()
+	})
+
+	It("Should wait for confirmation", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		Expect(ws.SubOrderContext(ctx, make(chan Order), nil)).To(Succeed())
+		Expect(ws.CancelAllAfterContext(ctx, time.Minute)).To(Succeed())
+	})
+
+	It("Should not send confirmed topic again", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		Expect(ws.SubTradeContext(ctx, make(chan WSTrade), []Contract{XBTUSD})).To(Succeed())
+		Expect(ws.SubTradeContext(ctx, make(chan WSTrade), []Contract{XBTUSD})).To(Succeed())
+		Expect(ws.SubTrade(make(chan WSTrade), []Contract{XBTUSD})).To(Succeed())
+		Consistently(func() int32 { return atomic.LoadInt32(&subscribes) }, 100*time.Millisecond).Should(BeEquivalentTo(1))
+	})
+
+	It("Should fail when context expires first", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := ws.SubPositionContext(ctx, make(chan Position), nil)
+		Expect(err).To(Equal(context.DeadlineExceeded))
+
+		ws.Lock()
+		Expect(ws.chSucc).NotTo(HaveKey("position"))
+		Expect(ws.chFail).NotTo(HaveKey("position"))
+		ws.Unlock()
+	})
+})
@@ -0,0 +1,89 @@
+package bitmex_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("WebsocketErrors", func() {
+	var tr *bitmextest.Transport
+	var ws *bitmex.WS
+	var server *bitmextest.MemConn
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+
+		tr = bitmextest.NewTransport()
+		ws = bitmex.NewWS(
+			bitmex.WithDialer(tr),
+			bitmex.WithHeartbeat(0, 0),
+			bitmex.WithReconnect(time.Hour, time.Hour),
+		)
+		Expect(ws.Connect()).To(Succeed())
+
+		var err error
+		server, err = tr.Accept(ctx)
+		Expect(err).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ws.Disconnect()
+		cancel()
+	})
+
+	It("Should report server error frames", func() {
+		server.WriteMessage([]byte(`{"status":400,"error":"Unknown table: trades","meta":{},"request":{"op":"subscribe","args":"trades"}}`))
+
+		Eventually(ws.Errors()).Should(Receive(MatchError(ContainSubstring("Unknown table: trades"))))
+	})
+
+	It("Should return error reply to request waiting for it", func() {
+		srv := bitmextest.NewServer("key", "secret")
+		defer srv.Close()
+
+		private := bitmex.NewWS(bitmex.WithDialer(srv.Dialer()), bitmex.WithHeartbeat(0, 0))
+		Expect(private.Connect()).To(Succeed())
+		defer private.Disconnect()
+
+		// Not authenticated, rejected long before deadline of ctx
+		start := time.Now()
+		err := private.SubOrderContext(ctx, make(chan bitmex.Order), nil)
+		Expect(err).To(MatchError(ContainSubstring("no authorization was provided")))
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(private.Errors()).NotTo(Receive())
+	})
+
+	It("Should report unknown and malformed messages", func() {
+		server.WriteMessage([]byte(`{"unexpected":true}`))
+		Eventually(ws.Errors()).Should(Receive(MatchError(ContainSubstring("Unknown WS message"))))
+
+		server.WriteMessage([]byte(`{"table":"trade","action":"insert","data":[`))
+		Eventually(ws.Errors()).Should(Receive(MatchError(ContainSubstring("Malformed WS message"))))
+	})
+
+	It("Should return error when writing to closed connection", func() {
+		server.Close()
+
+		// Reconnection waits for an hour, closed connection stays in place
+		Eventually(func() bitmex.ConnState {
+			select {
+			case e := <-ws.Events():
+				return e.State
+			default:
+				return -1
+			}
+		}).Should(Equal(bitmex.StateReconnecting))
+
+		trades := make(chan bitmex.WSTrade, 1)
+		err := ws.SubTrade(trades, []bitmex.Contract{bitmex.XBTUSD})
+		Expect(err).To(HaveOccurred())
+		Expect(err).NotTo(Equal(bitmex.ErrNotConnected))
+	})
+})
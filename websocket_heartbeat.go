@@ -0,0 +1,101 @@
+package bitmex
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var errPongTimeout = errors.New("WS pong timeout, dropping connection")
+
+//Latency - round trip time of the last ping/pong exchange
+func (ws *WS) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&ws.latency))
+}
+
+// heartbeat - sends ping after pingInterval without incoming data and drops
+// connection if pong is not received within pongTimeout, read then reconnects
+func (ws *WS) heartbeat() {
+	if ws.pingInterval <= 0 {
+		return
+	}
+
+	tick := ws.pingInterval
+	if ws.pongTimeout > 0 && ws.pongTimeout < tick {
+		tick = ws.pongTimeout
+	}
+
+	ticker := time.NewTicker(tick / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ws.quit:
+			return
+		case now := <-ticker.C:
+			ws.checkHeartbeat(now)
+		}
+	}
+}
+
+func (ws *WS) checkHeartbeat(now time.Time) {
+	if atomic.LoadInt32(&ws.reconnecting) != 0 {
+		return
+	}
+
+	if sent := atomic.LoadInt64(&ws.pingSent); sent != 0 {
+		if ws.pongTimeout > 0 && now.Sub(time.Unix(0, sent)) > ws.pongTimeout {
+			atomic.StoreInt64(&ws.pingSent, 0)
+
+			// Only connection the ping was sent on is dropped, it may
+			// have been replaced meanwhile
+			ws.Lock()
+			conn := ws.pingConn
+			ws.pingConn = nil
+			current := conn != nil && conn == ws.conn
+			ws.Unlock()
+
+			if !current {
+				return
+			}
+
+			atomic.StoreInt32(&ws.reconnecting, 1)
+			ws.error(errPongTimeout)
+			conn.Close()
+		}
+		return
+	}
+
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&ws.lastRecv))) < ws.pingInterval {
+		return
+	}
+
+	ws.Lock()
+	conn := ws.conn
+	ws.pingConn = conn
+	var err error
+	if conn != nil {
+		atomic.StoreInt64(&ws.pingSent, now.UnixNano())
+		err = conn.WriteMessage([]byte("ping"))
+	}
+	ws.Unlock()
+
+	if err != nil {
+		ws.error(fmt.Errorf("WS write: %v", err))
+	}
+}
+
+// received - marks connection alive, resolves outstanding ping on pong
+func (ws *WS) received(msg string) {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&ws.lastRecv, now)
+
+	if msg != "pong" {
+		return
+	}
+
+	if sent := atomic.SwapInt64(&ws.pingSent, 0); sent != 0 {
+		atomic.StoreInt64(&ws.latency, now-sent)
+	}
+}
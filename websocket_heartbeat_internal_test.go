@@ -0,0 +1,44 @@
+package bitmex
+
+import (
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// silentConn - connection which never answers
+type silentConn struct {
+	closed int32
+}
+
+func (c *silentConn) ReadMessage() ([]byte, error)      { select {} }
+func (c *silentConn) WriteMessage(msg []byte) error     { return nil }
+func (c *silentConn) SetReadDeadline(t time.Time) error { return nil }
+func (c *silentConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+var _ = Describe("Heartbeat", func() {
+	It("Should not drop connection replaced after ping", func() {
+		ws := NewWS(WithHeartbeat(time.Second, time.Second))
+		old, current := &silentConn{}, &silentConn{}
+		ws.conn = old
+
+		now := time.Now()
+		ws.checkHeartbeat(now.Add(2 * time.Second))
+		Expect(atomic.LoadInt64(&ws.pingSent)).NotTo(BeZero())
+
+		// Reconnected before pong timeout was noticed
+		ws.Lock()
+		ws.conn = current
+		ws.Unlock()
+
+		ws.checkHeartbeat(now.Add(4 * time.Second))
+		Expect(atomic.LoadInt32(&current.closed)).To(BeZero())
+		Expect(atomic.LoadInt32(&ws.reconnecting)).To(BeZero())
+		Expect(atomic.LoadInt64(&ws.pingSent)).To(BeZero())
+	})
+})
@@ -0,0 +1,90 @@
+package bitmex_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("Heartbeat", func() {
+	var tr *bitmextest.Transport
+	var ws *bitmex.WS
+	var server *bitmextest.MemConn
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+
+		tr = bitmextest.NewTransport()
+		ws = bitmex.NewWS(
+			bitmex.WithDialer(tr),
+			bitmex.WithHeartbeat(50*time.Millisecond, 100*time.Millisecond),
+			bitmex.WithReconnect(time.Millisecond, time.Millisecond),
+		)
+		Expect(ws.Connect()).To(Succeed())
+
+		var err error
+		server, err = tr.Accept(ctx)
+		Expect(err).To(Succeed())
+		server.SetReadDeadline(time.Now().Add(time.Second))
+	})
+
+	AfterEach(func() {
+		ws.Disconnect()
+		cancel()
+	})
+
+	It("Should ping idle connection", func() {
+		start := time.Now()
+
+		msg, err := server.ReadMessage()
+		Expect(err).To(Succeed())
+		Expect(string(msg)).To(Equal("ping"))
+		Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+	})
+
+	It("Should measure latency by pong", func() {
+		Expect(ws.Latency()).To(BeZero())
+
+		_, err := server.ReadMessage()
+		Expect(err).To(Succeed())
+
+		time.Sleep(20 * time.Millisecond)
+		Expect(server.WriteMessage([]byte("pong"))).To(Succeed())
+
+		Eventually(ws.Latency).Should(BeNumerically(">=", 20*time.Millisecond))
+		Expect(ws.Latency()).To(BeNumerically("<", time.Second))
+	})
+
+	It("Should reconnect when pong is missing", func() {
+		nextState := func() bitmex.ConnState {
+			select {
+			case e := <-ws.Events():
+				return e.State
+			case <-time.After(time.Second):
+				return -1
+			}
+		}
+		Expect(nextState()).To(Equal(bitmex.StateConnected))
+
+		msg, err := server.ReadMessage()
+		Expect(err).To(Succeed())
+		Expect(string(msg)).To(Equal("ping"))
+
+		Eventually(ws.Errors()).Should(Receive(MatchError(ContainSubstring("pong timeout"))))
+
+		// First connection is dropped and replaced
+		_, err = server.ReadMessage()
+		Expect(err).To(HaveOccurred())
+
+		_, err = tr.Accept(ctx)
+		Expect(err).To(Succeed())
+		Expect(nextState()).To(Equal(bitmex.StateReconnecting))
+		Expect(nextState()).To(Equal(bitmex.StateConnected))
+	})
+})
@@ -0,0 +1,119 @@
+package bitmex
+
+import (
+	"encoding/json"
+
+	"github.com/apex/log"
+)
+
+//SubOrderBook - maintains local order books of contracts from table
+//OrderBookL2 or OrderBookL225, ch (may be nil) receives book after every change
+func (ws *WS) SubOrderBook(ch chan *OrderBook, table string, contracts []Contract) error {
+	ws.Lock()
+
+	if ch != nil {
+		ws.chBook[ch] = append(ws.chBook[ch], contracts...)
+	}
+
+	for _, one := range contracts {
+		if _, ok := ws.books[one]; !ok {
+			ws.books[one] = NewOrderBook(one)
+		}
+	}
+
+	ws.Unlock()
+
+	for _, one := range contracts {
+		if err := ws.subscribe(table + ":" + string(one)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//OrderBook - local order book of contract, nil if not subscribed
+func (ws *WS) OrderBook(symbol Contract) *OrderBook {
+	ws.Lock()
+	defer ws.Unlock()
+	return ws.books[symbol]
+}
+
+func (ws *WS) orderBook(table wsData) {
+	var rows []OrderBookLevel
+	json.Unmarshal(table.Data, &rows)
+
+	// Rows are grouped per symbol keeping their order
+	var symbols []Contract
+	bySymbol := make(map[Contract][]OrderBookLevel)
+
+	for _, row := range rows {
+		if _, ok := bySymbol[row.Symbol]; !ok {
+			symbols = append(symbols, row.Symbol)
+		}
+		bySymbol[row.Symbol] = append(bySymbol[row.Symbol], row)
+	}
+
+	// Partial of per-contract topic resets its book even without rows, e.g.
+	// empty book of illiquid contract
+	if symbol, ok := table.filterSymbol(); ok && table.Action == "partial" {
+		if _, found := bySymbol[symbol]; !found {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	for _, symbol := range symbols {
+		ws.Lock()
+		book := ws.books[symbol]
+		ws.Unlock()
+
+		if book == nil {
+			continue
+		}
+
+		if err := book.apply(table.Action, bySymbol[symbol]); err != nil {
+			log.Warnf("Order book %s out of sync: %v, resubscribing", symbol, err)
+			ws.resubOrderBook(table.Table, symbol)
+			continue
+		}
+
+		ws.bookChanged(book)
+	}
+}
+
+func (ws *WS) resubOrderBook(table string, symbol Contract) {
+	topic := table + ":" + string(symbol)
+
+	if err := ws.send(`{"op": "unsubscribe", "args": "` + topic + `"}`); err != nil {
+		ws.error(err)
+		return
+	}
+	if err := ws.send(`{"op": "subscribe", "args": "` + topic + `"}`); err != nil {
+		ws.error(err)
+	}
+}
+
+func (ws *WS) bookChanged(book *OrderBook) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for ch, symbols := range ws.chBook {
+		if !hasContract(symbols, book.Symbol) {
+			continue
+		}
+
+		select {
+		case ch <- book:
+		default:
+			log.Debugf("Order book channel busy: %#v", ch)
+		}
+	}
+}
+
+func hasContract(contracts []Contract, symbol Contract) bool {
+	for _, one := range contracts {
+		if one == symbol {
+			return true
+		}
+	}
+	return false
+}
@@ -5,43 +5,66 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
 )
 
 //Auth - authentication
 func (ws *WS) Auth(key, secret string) chan struct{} {
+	ch := make(chan struct{})
+	ws.Lock()
 	ws.key = key
 	ws.secret = secret
+	ws.chSucc[ws.authOp()] = append(ws.chSucc[ws.authOp()], ch)
+	ws.Unlock()
 
-	nonce := ws.Nonce()
+	if err := ws.send(ws.authMessage()); err != nil {
+		ws.error(err)
+	}
 
-	req := fmt.Sprintf("GET/realtime%d", nonce)
-	signature := ws.sign(req)
+	return ch
+}
 
-	msg := fmt.Sprintf(
-		`{"op": "authKey", "args": ["%s", %d, "%s"]}`,
-		key, nonce, signature,
-	)
+// authOp - authKeyExpires unless legacy nonce authentication is configured
+func (ws *WS) authOp() string {
+	if ws.expires > 0 {
+		return "authKeyExpires"
+	}
+	return "authKey"
+}
 
-	ch := make(chan struct{})
+func (ws *WS) authMessage() string {
 	ws.Lock()
-	ws.chSucc["authKey"] = append(ws.chSucc["authKey"], ch)
+	key, secret := ws.key, ws.secret
 	ws.Unlock()
 
-	ws.send(msg)
+	var nonce int64
+	if ws.expires > 0 {
+		nonce = time.Now().Add(ws.expires).Unix()
+	} else {
+		nonce = ws.Nonce()
+	}
 
-	return ch
+	req := fmt.Sprintf("GET/realtime%d", nonce)
+	signature := sign(secret, req)
+
+	return fmt.Sprintf(
+		`{"op": "%s", "args": ["%s", %d, "%s"]}`,
+		ws.authOp(), key, nonce, signature,
+	)
 }
 
-func (ws *WS) sign(payload string) string {
-	sig := hmac.New(sha256.New, []byte(ws.secret))
+func sign(secret, payload string) string {
+	sig := hmac.New(sha256.New, []byte(secret))
 	sig.Write([]byte(payload))
 	return hex.EncodeToString(sig.Sum(nil))
 }
 
-//Nonce - gets next nonce
+//Nonce - gets next nonce, safe for concurrent use
 func (ws *WS) Nonce() int64 {
-	ws.nonce++
-	return ws.nonce
+	return atomic.AddInt64(&ws.nonce, 1)
 }
 
 //SubOrder - subscribe to order events
@@ -60,7 +83,7 @@ func (ws *WS) SubOrder(ch chan Order, contracts []Contract) chan struct{} {
 }
 
 //SubPosition - subscribe to position chage events
-func (ws *WS) SubPosition(ch chan WSPosition, contracts []Contract) chan struct{} {
+func (ws *WS) SubPosition(ch chan Position, contracts []Contract) chan struct{} {
 	ws.Lock()
 
 	if _, ok := ws.chPosition[ch]; !ok {
@@ -81,8 +104,89 @@ func (ws *WS) subPrivate(topic string) chan struct{} {
 	ws.chSucc[topic] = append(ws.chSucc[topic], ch)
 	ws.Unlock()
 
-	ws.send(`{"op": "subscribe", "args": "` + topic + `"}`)
+	if err := ws.subscribe(topic); err != nil {
+		ws.error(err)
+	}
 
 	return ch
 
 }
+
+//SubExecution - subscribe to executions (fills) of contracts, all if empty
+func (ws *WS) SubExecution(ch chan Execution, contracts []Contract) chan struct{} {
+	ws.Lock()
+
+	if _, ok := ws.chExec[ch]; !ok {
+		ws.chExec[ch] = contracts
+	} else {
+		ws.chExec[ch] = append(ws.chExec[ch], contracts...)
+	}
+
+	ws.Unlock()
+
+	return ws.subPrivate("execution")
+}
+
+func (ws *WS) execution(execution Execution) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for ch, symbols := range ws.chExec {
+		if len(symbols) > 0 && !hasContract(symbols, execution.Symbol) {
+			continue
+		}
+
+		select {
+		case ch <- execution:
+			log.Debugf("Execution sent: %#v - %#v", ch, execution)
+		default:
+			log.Debugf("Execution channel busy: %#v", ch)
+		}
+	}
+}
+
+//SubMargin - subscribe to account margin changes
+func (ws *WS) SubMargin(ch chan Margin) chan struct{} {
+	ws.Lock()
+	ws.chMargin[ch] = struct{}{}
+	ws.Unlock()
+
+	return ws.subPrivate("margin")
+}
+
+//SubWallet - subscribe to wallet balance changes
+func (ws *WS) SubWallet(ch chan Wallet) chan struct{} {
+	ws.Lock()
+	ws.chWallet[ch] = struct{}{}
+	ws.Unlock()
+
+	return ws.subPrivate("wallet")
+}
+
+func (ws *WS) margin(margin Margin) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for ch := range ws.chMargin {
+		select {
+		case ch <- margin:
+			log.Debugf("Margin sent: %#v - %#v", ch, margin)
+		default:
+			log.Debugf("Margin channel busy: %#v", ch)
+		}
+	}
+}
+
+func (ws *WS) wallet(wallet Wallet) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for ch := range ws.chWallet {
+		select {
+		case ch <- wallet:
+			log.Debugf("Wallet sent: %#v - %#v", ch, wallet)
+		default:
+			log.Debugf("Wallet channel busy: %#v", ch)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package bitmex
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ConnState - state of websocket connection
+type ConnState int
+
+// Connection states reported on WS.Events
+const (
+	// StateConnected - connection (re)established, subscriptions are not restored yet
+	StateConnected ConnState = iota
+	// StateReconnecting - connection lost, data may be missing until StateResynced
+	StateReconnecting
+	// StateResynced - server confirmed replayed authentication and all
+	// subscriptions, not reported if it does not within resyncTimeout
+	StateResynced
+	// StateDisconnected - Disconnect was called
+	StateDisconnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateResynced:
+		return "resynced"
+	case StateDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// Event - connection state change
+type Event struct {
+	State ConnState
+	Err   error
+	Time  time.Time
+}
+
+//Events - channel of connection state changes, events are dropped if
+//nobody reads them
+func (ws *WS) Events() <-chan Event {
+	return ws.events
+}
+
+func (ws *WS) event(state ConnState, err error) {
+	select {
+	case ws.events <- Event{State: state, Err: err, Time: time.Now()}:
+	default:
+		log.Debugf("Event channel busy, dropped: %v", state)
+	}
+}
+
+// resyncTimeout - how long server has to confirm replayed authentication
+// and subscriptions
+const resyncTimeout = 10 * time.Second
+
+func (ws *WS) dial() (Conn, error) {
+	return ws.dialer.Dial(context.Background(), ws.url)
+}
+
+// backoff - exponential delay with jitter, between half and full step
+func backoff(min, max time.Duration, attempt int) time.Duration {
+	step := min
+	for i := 0; i < attempt && step < max; i++ {
+		step *= 2
+	}
+	if step > max {
+		step = max
+	}
+
+	half := int64(step / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// reconnect - dials until success, returns false if WS was disconnected meanwhile
+func (ws *WS) reconnect(cause error) bool {
+	log.Warnf("WS connection lost: %v", cause)
+	ws.event(StateReconnecting, cause)
+
+	atomic.StoreInt32(&ws.reconnecting, 1)
+	defer atomic.StoreInt32(&ws.reconnecting, 0)
+
+	ws.Lock()
+	for _, book := range ws.books {
+		book.reset()
+	}
+	ws.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-ws.quit:
+			return false
+		case <-time.After(backoff(ws.reconnectMin, ws.reconnectMax, attempt)):
+		}
+
+		conn, err := ws.dial()
+		if err != nil {
+			ws.error(fmt.Errorf("WS reconnect attempt %d failed: %v", attempt+1, err))
+			continue
+		}
+
+		ws.Lock()
+		select {
+		case <-ws.quit:
+			ws.Unlock()
+			conn.Close()
+			return false
+		default:
+		}
+		ws.conn.Close()
+		ws.conn = conn
+		ws.subscribed = make(map[string]bool, 0)
+		ws.unsubscribedAll()
+		ws.Unlock()
+
+		atomic.StoreInt64(&ws.pingSent, 0)
+		atomic.StoreInt64(&ws.lastRecv, time.Now().UnixNano())
+
+		log.Info("Reconnected")
+		ws.event(StateConnected, nil)
+
+		// Confirmations are read by caller, so resync can't block it
+		go func() {
+			if err := ws.resync(); err != nil {
+				ws.error(fmt.Errorf("WS resync: %v", err))
+				return
+			}
+			ws.event(StateResynced, nil)
+		}()
+
+		return true
+	}
+}
+
+// resync - replays authentication and every subscribed topic, waits for
+// server to confirm each of them
+func (ws *WS) resync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), resyncTimeout)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-ws.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ws.Lock()
+	authenticated := ws.key != ""
+	topics := make([]string, 0, len(ws.topics))
+	for topic := range ws.topics {
+		topics = append(topics, topic)
+	}
+	ws.Unlock()
+
+	// Private topics need authentication confirmed first
+	if authenticated {
+		err := ws.await(ctx, ws.authOp(), func() error {
+			return ws.send(ws.authMessage())
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, topic := range topics {
+		err := ws.await(ctx, topic, func() error {
+			return ws.sendSubscribe(topic)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %v", topic, err)
+		}
+	}
+
+	return nil
+}
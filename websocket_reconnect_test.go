@@ -0,0 +1,64 @@
+package bitmex_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+var _ = Describe("Reconnect", func() {
+	It("Should replay authentication and subscriptions", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		srv := bitmextest.NewServer("key", "secret")
+		defer srv.Close()
+
+		ws := bitmex.NewWS(
+			bitmex.WithDialer(srv.Dialer()),
+			bitmex.WithHeartbeat(0, 0),
+			bitmex.WithReconnect(time.Millisecond, time.Millisecond),
+		)
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		Expect(ws.AuthContext(ctx, "key", "secret")).To(Succeed())
+
+		positions := make(chan bitmex.Position, 1)
+		trades := make(chan bitmex.WSTrade, 1)
+		Expect(ws.SubPositionContext(ctx, positions, nil)).To(Succeed())
+		Expect(ws.SubTradeContext(ctx, trades, []bitmex.Contract{bitmex.XBTUSD})).To(Succeed())
+
+		nextState := func() bitmex.ConnState {
+			select {
+			case e := <-ws.Events():
+				return e.State
+			case <-ctx.Done():
+				return -1
+			}
+		}
+		Expect(nextState()).To(Equal(bitmex.StateConnected))
+
+		srv.DropConnections()
+
+		Expect(nextState()).To(Equal(bitmex.StateReconnecting))
+		Expect(nextState()).To(Equal(bitmex.StateConnected))
+		Expect(nextState()).To(Equal(bitmex.StateResynced))
+
+		// Confirmed by now, private topic proves authentication was replayed
+		Expect(srv.Subscribed("position")).To(BeTrue())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeTrue())
+
+		srv.SetPosition(bitmex.Position{Symbol: bitmex.XBTUSD, CurrentQty: 100})
+		var p bitmex.Position
+		Eventually(positions).Should(Receive(&p))
+		Expect(p.CurrentQty).To(BeEquivalentTo(100))
+
+		srv.Insert("trade:XBTUSD", []bitmex.WSTrade{{Symbol: "XBTUSD", Price: 6500}})
+		Eventually(trades).Should(Receive())
+	})
+})
@@ -0,0 +1,59 @@
+package bitmex
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Replay - feeds recorded frames through the same handling as live messages,
+// so channels subscribed by Sub* methods receive them. Speed 1 keeps original
+// pace, 10 replays ten times faster and 0 as fast as possible. WS doesn't
+// need to be connected, Disconnect stops replay
+func (ws *WS) Replay(src io.Reader, speed float64) error {
+	frames, err := NewFrameReader(src)
+	if err != nil {
+		return err
+	}
+
+	var last time.Time
+	for {
+		frame, err := frames.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if speed > 0 && !last.IsZero() {
+			if delay := frame.Time.Sub(last); delay > 0 {
+				select {
+				case <-time.After(time.Duration(float64(delay) / speed)):
+				case <-ws.quit:
+					return nil
+				}
+			}
+		}
+		last = frame.Time
+
+		select {
+		case <-ws.quit:
+			return nil
+		default:
+		}
+
+		ws.dispatch(frame.Msg)
+	}
+}
+
+// ReplayFile - replays recording written by Recorder
+func (ws *WS) ReplayFile(path string, speed float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ws.Replay(f, speed)
+}
@@ -0,0 +1,513 @@
+package bitmex
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/apex/log"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Funding - funding rate charged at funding timestamp
+type Funding struct {
+	Timestamp        time.Time `json:"timestamp"`
+	Symbol           Contract  `json:"symbol"`
+	FundingInterval  time.Time `json:"fundingInterval"`
+	FundingRate      float64   `json:"fundingRate"`
+	FundingRateDaily float64   `json:"fundingRateDaily"`
+}
+
+// Liquidation - liquidation order in the book. Rows removed by server
+// (filled or cancelled) are sent with zero LeavesQty
+type Liquidation struct {
+	OrderID   uuid.UUID `json:"orderID"`
+	Symbol    Contract  `json:"symbol"`
+	Side      string    `json:"side"`
+	Price     float64   `json:"price"`
+	LeavesQty float64   `json:"leavesQty"`
+}
+
+// Settlement - historical settlement or contract expiry
+type Settlement struct {
+	Timestamp             time.Time `json:"timestamp"`
+	Symbol                Contract  `json:"symbol"`
+	SettlementType        string    `json:"settlementType"`
+	SettledPrice          float64   `json:"settledPrice"`
+	OptionStrikePrice     float64   `json:"optionStrikePrice"`
+	OptionUnderlyingPrice float64   `json:"optionUnderlyingPrice"`
+	Bankrupt              int64     `json:"bankrupt"`
+	TaxBase               int64     `json:"taxBase"`
+	TaxRate               float64   `json:"taxRate"`
+}
+
+// Insurance - daily insurance fund balance
+type Insurance struct {
+	Currency      string    `json:"currency"`
+	Timestamp     time.Time `json:"timestamp"`
+	WalletBalance Satoshi   `json:"walletBalance"`
+}
+
+// IndexPrice - price of underlying index, for perpetual contracts
+// indicative settle price is the index
+func (i Instrument) IndexPrice() float64 {
+	return i.IndicativeSettlePrice
+}
+
+// PredictedFundingRate - funding rate of next funding interval
+func (i Instrument) PredictedFundingRate() float64 {
+	return i.IndicativeFundingRate
+}
+
+// subTable - subscribes to table topic of every contract, whole table if
+// there are none
+func (ws *WS) subTable(table string, contracts []Contract) error {
+	if len(contracts) == 0 {
+		return ws.subscribe(table)
+	}
+
+	for _, one := range contracts {
+		if err := ws.subscribe(table + ":" + string(one)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subTableContext - subTable waiting for confirmation of every topic
+func (ws *WS) subTableContext(ctx context.Context, table string, contracts []Contract) error {
+	if len(contracts) == 0 {
+		return ws.subscribeContext(ctx, table)
+	}
+	return ws.subscribeAll(ctx, table, contracts)
+}
+
+// wantsContract - whether channel subscribed to symbols (all if empty)
+// receives row of symbol
+func wantsContract(symbols []Contract, symbol Contract) bool {
+	return len(symbols) == 0 || hasContract(symbols, symbol)
+}
+
+// SubInstrument - subscribes channel to instrument updates of contracts (all
+// if empty). Every update carries full instrument with merged fields
+func (ws *WS) SubInstrument(ch chan Instrument, contracts []Contract) error {
+	ws.Lock()
+	ws.chInstrument[ch] = append(ws.chInstrument[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTable("instrument", contracts)
+}
+
+// SubFunding - subscribes channel to funding of contracts (all if empty)
+func (ws *WS) SubFunding(ch chan Funding, contracts []Contract) error {
+	ws.Lock()
+	ws.chFunding[ch] = append(ws.chFunding[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTable("funding", contracts)
+}
+
+// SubLiquidation - subscribes channel to liquidation orders of contracts
+// (all if empty)
+func (ws *WS) SubLiquidation(ch chan Liquidation, contracts []Contract) error {
+	ws.Lock()
+	ws.chLiq[ch] = append(ws.chLiq[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTable("liquidation", contracts)
+}
+
+// SubSettlement - subscribes channel to settlements of contracts (all if
+// empty)
+func (ws *WS) SubSettlement(ch chan Settlement, contracts []Contract) error {
+	ws.Lock()
+	ws.chSettlement[ch] = append(ws.chSettlement[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTable("settlement", contracts)
+}
+
+// SubInsurance - subscribes channel to insurance fund updates
+func (ws *WS) SubInsurance(ch chan Insurance) error {
+	ws.Lock()
+	ws.chInsurance[ch] = struct{}{}
+	ws.Unlock()
+
+	return ws.subscribe("insurance")
+}
+
+// SubInstrumentContext - SubInstrument waiting for confirmation
+func (ws *WS) SubInstrumentContext(ctx context.Context, ch chan Instrument, contracts []Contract) error {
+	ws.Lock()
+	ws.chInstrument[ch] = append(ws.chInstrument[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTableContext(ctx, "instrument", contracts)
+}
+
+// SubFundingContext - SubFunding waiting for confirmation
+func (ws *WS) SubFundingContext(ctx context.Context, ch chan Funding, contracts []Contract) error {
+	ws.Lock()
+	ws.chFunding[ch] = append(ws.chFunding[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTableContext(ctx, "funding", contracts)
+}
+
+// SubLiquidationContext - SubLiquidation waiting for confirmation
+func (ws *WS) SubLiquidationContext(ctx context.Context, ch chan Liquidation, contracts []Contract) error {
+	ws.Lock()
+	ws.chLiq[ch] = append(ws.chLiq[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTableContext(ctx, "liquidation", contracts)
+}
+
+// SubSettlementContext - SubSettlement waiting for confirmation
+func (ws *WS) SubSettlementContext(ctx context.Context, ch chan Settlement, contracts []Contract) error {
+	ws.Lock()
+	ws.chSettlement[ch] = append(ws.chSettlement[ch], contracts...)
+	ws.Unlock()
+
+	return ws.subTableContext(ctx, "settlement", contracts)
+}
+
+// SubInsuranceContext - SubInsurance waiting for confirmation
+func (ws *WS) SubInsuranceContext(ctx context.Context, ch chan Insurance) error {
+	ws.Lock()
+	ws.chInsurance[ch] = struct{}{}
+	ws.Unlock()
+
+	return ws.subscribeContext(ctx, "insurance")
+}
+
+// UnsubInstrument - removes contracts (all if empty) from instrument channel
+func (ws *WS) UnsubInstrument(ch chan Instrument, contracts []Contract) (chan struct{}, error) {
+	ws.removeInstrument(ch, contracts)
+	return ws.unsubUnused()
+}
+
+// UnsubInstrumentContext - UnsubInstrument waiting for confirmation
+func (ws *WS) UnsubInstrumentContext(ctx context.Context, ch chan Instrument, contracts []Contract) error {
+	ws.removeInstrument(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeInstrument(ch chan Instrument, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chInstrument[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chInstrument, ch)
+		} else {
+			ws.chInstrument[ch] = rest
+		}
+	}
+}
+
+// UnsubFunding - removes contracts (all if empty) from funding channel
+func (ws *WS) UnsubFunding(ch chan Funding, contracts []Contract) (chan struct{}, error) {
+	ws.removeFunding(ch, contracts)
+	return ws.unsubUnused()
+}
+
+// UnsubFundingContext - UnsubFunding waiting for confirmation
+func (ws *WS) UnsubFundingContext(ctx context.Context, ch chan Funding, contracts []Contract) error {
+	ws.removeFunding(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeFunding(ch chan Funding, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chFunding[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chFunding, ch)
+		} else {
+			ws.chFunding[ch] = rest
+		}
+	}
+}
+
+// UnsubLiquidation - removes contracts (all if empty) from liquidation channel
+func (ws *WS) UnsubLiquidation(ch chan Liquidation, contracts []Contract) (chan struct{}, error) {
+	ws.removeLiquidation(ch, contracts)
+	return ws.unsubUnused()
+}
+
+// UnsubLiquidationContext - UnsubLiquidation waiting for confirmation
+func (ws *WS) UnsubLiquidationContext(ctx context.Context, ch chan Liquidation, contracts []Contract) error {
+	ws.removeLiquidation(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeLiquidation(ch chan Liquidation, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chLiq[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chLiq, ch)
+		} else {
+			ws.chLiq[ch] = rest
+		}
+	}
+}
+
+// UnsubSettlement - removes contracts (all if empty) from settlement channel
+func (ws *WS) UnsubSettlement(ch chan Settlement, contracts []Contract) (chan struct{}, error) {
+	ws.removeSettlement(ch, contracts)
+	return ws.unsubUnused()
+}
+
+// UnsubSettlementContext - UnsubSettlement waiting for confirmation
+func (ws *WS) UnsubSettlementContext(ctx context.Context, ch chan Settlement, contracts []Contract) error {
+	ws.removeSettlement(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeSettlement(ch chan Settlement, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chSettlement[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chSettlement, ch)
+		} else {
+			ws.chSettlement[ch] = rest
+		}
+	}
+}
+
+// UnsubInsurance - removes insurance channel
+func (ws *WS) UnsubInsurance(ch chan Insurance) (chan struct{}, error) {
+	ws.removeInsurance(ch)
+	return ws.unsubUnused()
+}
+
+// UnsubInsuranceContext - UnsubInsurance waiting for confirmation
+func (ws *WS) UnsubInsuranceContext(ctx context.Context, ch chan Insurance) error {
+	ws.removeInsurance(ch)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeInsurance(ch chan Insurance) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	delete(ws.chInsurance, ch)
+}
+
+// SubTradeBin - subscribes channel to candles of interval for contracts (all
+// if empty). Candle is sent when its bin is closed
+func (ws *WS) SubTradeBin(interval BinSize, ch chan Candle, contracts []Contract) error {
+	ws.addBin(interval, ch, contracts)
+	return ws.subTable(tradeBinTopic(interval), contracts)
+}
+
+// SubTradeBinContext - SubTradeBin waiting for confirmation
+func (ws *WS) SubTradeBinContext(ctx context.Context, interval BinSize, ch chan Candle, contracts []Contract) error {
+	ws.addBin(interval, ch, contracts)
+	return ws.subTableContext(ctx, tradeBinTopic(interval), contracts)
+}
+
+func (ws *WS) addBin(interval BinSize, ch chan Candle, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if _, ok := ws.chBin[interval]; !ok {
+		ws.chBin[interval] = make(map[chan Candle][]Contract)
+	}
+	ws.chBin[interval][ch] = append(ws.chBin[interval][ch], contracts...)
+}
+
+// UnsubTradeBin - removes contracts (all if empty) from candle channel of
+// interval
+func (ws *WS) UnsubTradeBin(interval BinSize, ch chan Candle, contracts []Contract) (chan struct{}, error) {
+	ws.removeTradeBin(interval, ch, contracts)
+	return ws.unsubUnused()
+}
+
+// UnsubTradeBinContext - UnsubTradeBin waiting for confirmation
+func (ws *WS) UnsubTradeBinContext(ctx context.Context, interval BinSize, ch chan Candle, contracts []Contract) error {
+	ws.removeTradeBin(interval, ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeTradeBin(interval BinSize, ch chan Candle, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chBin[interval][ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chBin[interval], ch)
+		} else {
+			ws.chBin[interval][ch] = rest
+		}
+	}
+}
+
+// neededStreams - topics of channels in this file, must be called locked
+func (ws *WS) neededStreams(needed map[string]bool) {
+	add := func(table string, symbols []Contract) {
+		if len(symbols) == 0 {
+			needed[table] = true
+		}
+		for _, one := range symbols {
+			needed[table+":"+string(one)] = true
+		}
+	}
+
+	for _, symbols := range ws.chInstrument {
+		add("instrument", symbols)
+	}
+	for _, symbols := range ws.chFunding {
+		add("funding", symbols)
+	}
+	for _, symbols := range ws.chLiq {
+		add("liquidation", symbols)
+	}
+	for _, symbols := range ws.chSettlement {
+		add("settlement", symbols)
+	}
+	if len(ws.chInsurance) > 0 {
+		needed["insurance"] = true
+	}
+	for interval, channels := range ws.chBin {
+		for _, symbols := range channels {
+			add(tradeBinTopic(interval), symbols)
+		}
+	}
+}
+
+// stream - dispatches message of streams in this file
+func (ws *WS) stream(msg wsData) {
+	switch msg.Table {
+	case "instrument":
+		var rows []Instrument
+		json.Unmarshal(ws.applyTable(msg), &rows)
+
+		ws.Lock()
+		defer ws.Unlock()
+
+		for _, one := range rows {
+			for ch, symbols := range ws.chInstrument {
+				if !wantsContract(symbols, one.Symbol) {
+					continue
+				}
+				select {
+				case ch <- one:
+				default:
+					log.Debugf("Instrument channel busy: %#v", ch)
+				}
+			}
+		}
+
+	case "funding":
+		var rows []Funding
+		json.Unmarshal(msg.Data, &rows)
+
+		ws.Lock()
+		defer ws.Unlock()
+
+		for _, one := range rows {
+			for ch, symbols := range ws.chFunding {
+				if !wantsContract(symbols, one.Symbol) {
+					continue
+				}
+				select {
+				case ch <- one:
+				default:
+					log.Debugf("Funding channel busy: %#v", ch)
+				}
+			}
+		}
+
+	case "liquidation":
+		var rows []Liquidation
+		json.Unmarshal(ws.applyTable(msg), &rows)
+
+		ws.Lock()
+		defer ws.Unlock()
+
+		for _, one := range rows {
+			if msg.Action == "delete" {
+				one.LeavesQty = 0
+			}
+			for ch, symbols := range ws.chLiq {
+				if !wantsContract(symbols, one.Symbol) {
+					continue
+				}
+				select {
+				case ch <- one:
+				default:
+					log.Debugf("Liquidation channel busy: %#v", ch)
+				}
+			}
+		}
+
+	case "settlement":
+		var rows []Settlement
+		json.Unmarshal(msg.Data, &rows)
+
+		ws.Lock()
+		defer ws.Unlock()
+
+		for _, one := range rows {
+			for ch, symbols := range ws.chSettlement {
+				if !wantsContract(symbols, one.Symbol) {
+					continue
+				}
+				select {
+				case ch <- one:
+				default:
+					log.Debugf("Settlement channel busy: %#v", ch)
+				}
+			}
+		}
+
+	case "insurance":
+		var rows []Insurance
+		json.Unmarshal(msg.Data, &rows)
+
+		ws.Lock()
+		defer ws.Unlock()
+
+		for _, one := range rows {
+			for ch := range ws.chInsurance {
+				select {
+				case ch <- one:
+				default:
+					log.Debugf("Insurance channel busy: %#v", ch)
+				}
+			}
+		}
+
+	default:
+		interval, ok := binSizeOf(msg.Table)
+		if !ok {
+			return
+		}
+
+		var rows []Candle
+		json.Unmarshal(msg.Data, &rows)
+
+		ws.Lock()
+		defer ws.Unlock()
+
+		for _, one := range rows {
+			for ch, symbols := range ws.chBin[interval] {
+				if !wantsContract(symbols, one.Symbol) {
+					continue
+				}
+				select {
+				case ch <- one:
+				default:
+					log.Debugf("Candle channel busy: %#v", ch)
+				}
+			}
+		}
+	}
+}
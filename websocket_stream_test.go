@@ -0,0 +1,120 @@
+package bitmex
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Streams", func() {
+	It("Should filter instruments by contract", func() {
+		ws := NewWS()
+		xbt := make(chan Instrument, 2)
+		all := make(chan Instrument, 4)
+		Expect(ws.SubInstrument(xbt, []Contract{XBTUSD})).To(Succeed())
+		Expect(ws.SubInstrument(all, nil)).To(Succeed())
+
+		ws.dispatch(`{"table":"instrument","action":"partial","keys":["symbol"],"data":[
+			{"symbol":"XBTUSD","markPrice":6500,"indicativeSettlePrice":6490,"fundingRate":0.0001,"indicativeFundingRate":0.0003,"openInterest":1000},
+			{"symbol":"ETHUSD","markPrice":300}
+		]}`)
+		ws.dispatch(`{"table":"instrument","action":"update","data":[{"symbol":"XBTUSD","markPrice":6510}]}`)
+
+		Expect(all).To(HaveLen(3))
+		Expect(xbt).To(Receive())
+
+		var one Instrument
+		Expect(xbt).To(Receive(&one))
+		Expect(one.MarkPrice).To(Equal(6510.0))
+		Expect(one.IndexPrice()).To(Equal(6490.0))
+		Expect(one.FundingRate).To(Equal(0.0001))
+		Expect(one.PredictedFundingRate()).To(Equal(0.0003))
+		Expect(one.OpenInterest).To(BeEquivalentTo(1000))
+		Expect(xbt).NotTo(Receive())
+
+		ws.Lock()
+		needed := ws.neededTopics()
+		ws.Unlock()
+		Expect(needed).To(HaveKey("instrument"))
+		Expect(needed).To(HaveKey("instrument:XBTUSD"))
+	})
+
+	It("Should keep instruments of other per-contract partials", func() {
+		ws := NewWS()
+		instruments := make(chan Instrument, 4)
+		Expect(ws.SubInstrument(instruments, []Contract{XBTUSD, "ETHUSD"})).To(Succeed())
+
+		ws.dispatch(`{"table":"instrument","action":"partial","keys":["symbol"],"filter":{"symbol":"XBTUSD"},"data":[
+			{"symbol":"XBTUSD","tickSize":0.5,"markPrice":6500}
+		]}`)
+		ws.dispatch(`{"table":"instrument","action":"partial","keys":["symbol"],"filter":{"symbol":"ETHUSD"},"data":[
+			{"symbol":"ETHUSD","tickSize":0.05,"markPrice":300}
+		]}`)
+		ws.dispatch(`{"table":"instrument","action":"update","data":[{"symbol":"XBTUSD","markPrice":6510}]}`)
+
+		Expect(instruments).To(HaveLen(3))
+		Expect(ws.Table("instrument").Len()).To(Equal(2))
+
+		var xbt Instrument
+		found, err := ws.Table("instrument").Get(&xbt, XBTUSD)
+		Expect(err).To(Succeed())
+		Expect(found).To(BeTrue())
+		Expect(xbt.TickSize).To(Equal(0.5))
+		Expect(xbt.MarkPrice).To(Equal(6510.0))
+
+		var eth Instrument
+		found, err = ws.Table("instrument").Get(&eth, "ETHUSD")
+		Expect(err).To(Succeed())
+		Expect(found).To(BeTrue())
+		Expect(eth.TickSize).To(Equal(0.05))
+	})
+
+	It("Should send removed liquidations without leaves", func() {
+		ws := NewWS()
+		liquidations := make(chan Liquidation, 2)
+		Expect(ws.SubLiquidation(liquidations, []Contract{XBTUSD})).To(Succeed())
+
+		ws.dispatch(`{"table":"liquidation","action":"insert","data":[
+			{"orderID":"00000000-0000-0000-0000-00000000000a","symbol":"XBTUSD","side":"Sell","price":6400,"leavesQty":5000},
+			{"orderID":"00000000-0000-0000-0000-00000000000b","symbol":"ETHUSD","side":"Buy","price":310,"leavesQty":10}
+		]}`)
+		ws.dispatch(`{"table":"liquidation","action":"delete","data":[{"orderID":"00000000-0000-0000-0000-00000000000a"}]}`)
+
+		var l Liquidation
+		Expect(liquidations).To(Receive(&l))
+		Expect(l.Side).To(Equal("Sell"))
+		Expect(l.LeavesQty).To(Equal(5000.0))
+
+		Expect(liquidations).To(Receive(&l))
+		Expect(l.Price).To(Equal(6400.0))
+		Expect(l.LeavesQty).To(BeZero())
+	})
+
+	It("Should forward funding and insurance", func() {
+		ws := NewWS()
+		funding := make(chan Funding, 1)
+		insurance := make(chan Insurance, 1)
+		Expect(ws.SubFunding(funding, nil)).To(Succeed())
+		Expect(ws.SubInsurance(insurance)).To(Succeed())
+
+		ws.dispatch(`{"table":"funding","action":"insert","data":[{"symbol":"XBTUSD","fundingRate":-0.000375,"fundingRateDaily":-0.001125}]}`)
+		ws.dispatch(`{"table":"insurance","action":"insert","data":[{"currency":"XBt","walletBalance":2000000000}]}`)
+
+		var f Funding
+		Expect(funding).To(Receive(&f))
+		Expect(f.Symbol).To(Equal(XBTUSD))
+		Expect(f.FundingRate).To(Equal(-0.000375))
+
+		var i Insurance
+		Expect(insurance).To(Receive(&i))
+		Expect(i.WalletBalance.XBT()).To(Equal(20.0))
+
+		// not connected, only listeners are dropped
+		ws.UnsubFunding(funding, nil)
+
+		ws.Lock()
+		needed := ws.neededTopics()
+		ws.Unlock()
+		Expect(needed).NotTo(HaveKey("funding"))
+		Expect(needed).To(HaveKey("insurance"))
+	})
+})
@@ -0,0 +1,431 @@
+package bitmex
+
+import (
+	"context"
+	"strings"
+)
+
+// removeContracts - drops contracts from subscription, empty contracts or
+// nothing left means channel has to be removed completely. Channel
+// subscribed to all contracts can only be removed completely
+func removeContracts(symbols, contracts []Contract) ([]Contract, bool) {
+	if len(contracts) == 0 {
+		return nil, true
+	}
+
+	if len(symbols) == 0 {
+		return symbols, false
+	}
+
+	var rest []Contract
+	for _, one := range symbols {
+		if !hasContract(contracts, one) {
+			rest = append(rest, one)
+		}
+	}
+
+	return rest, len(rest) == 0
+}
+
+//UnsubTrade - removes contracts (all if empty) from trade channel, returned
+//channel is closed when server confirms all no longer needed unsubscriptions
+func (ws *WS) UnsubTrade(ch chan WSTrade, contracts []Contract) (chan struct{}, error) {
+	ws.removeTrade(ch, contracts)
+	return ws.unsubUnused()
+}
+
+//UnsubTradeContext - UnsubTrade waiting for confirmation
+func (ws *WS) UnsubTradeContext(ctx context.Context, ch chan WSTrade, contracts []Contract) error {
+	ws.removeTrade(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeTrade(ch chan WSTrade, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chTrade[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chTrade, ch)
+		} else {
+			ws.chTrade[ch] = rest
+		}
+	}
+}
+
+//UnsubQuote - removes contracts (all if empty) from quote channel
+func (ws *WS) UnsubQuote(ch chan WSQuote, contracts []Contract) (chan struct{}, error) {
+	ws.removeQuote(ch, contracts)
+	return ws.unsubUnused()
+}
+
+//UnsubQuoteContext - UnsubQuote waiting for confirmation
+func (ws *WS) UnsubQuoteContext(ctx context.Context, ch chan WSQuote, contracts []Contract) error {
+	ws.removeQuote(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeQuote(ch chan WSQuote, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chQuote[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chQuote, ch)
+		} else {
+			ws.chQuote[ch] = rest
+		}
+	}
+}
+
+//UnsubOrder - removes contracts (all if empty) from order channel
+func (ws *WS) UnsubOrder(ch chan Order, contracts []Contract) (chan struct{}, error) {
+	ws.removeOrder(ch, contracts)
+	return ws.unsubUnused()
+}
+
+//UnsubOrderContext - UnsubOrder waiting for confirmation
+func (ws *WS) UnsubOrderContext(ctx context.Context, ch chan Order, contracts []Contract) error {
+	ws.removeOrder(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeOrder(ch chan Order, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chOrder[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chOrder, ch)
+		} else {
+			ws.chOrder[ch] = rest
+		}
+	}
+}
+
+//UnsubPosition - removes contracts (all if empty) from position channel
+func (ws *WS) UnsubPosition(ch chan Position, contracts []Contract) (chan struct{}, error) {
+	ws.removePosition(ch, contracts)
+	return ws.unsubUnused()
+}
+
+//UnsubPositionContext - UnsubPosition waiting for confirmation
+func (ws *WS) UnsubPositionContext(ctx context.Context, ch chan Position, contracts []Contract) error {
+	ws.removePosition(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removePosition(ch chan Position, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chPosition[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chPosition, ch)
+		} else {
+			ws.chPosition[ch] = rest
+		}
+	}
+}
+
+//UnsubExecution - removes contracts (all if empty) from execution channel
+func (ws *WS) UnsubExecution(ch chan Execution, contracts []Contract) (chan struct{}, error) {
+	ws.removeExecution(ch, contracts)
+	return ws.unsubUnused()
+}
+
+//UnsubExecutionContext - UnsubExecution waiting for confirmation
+func (ws *WS) UnsubExecutionContext(ctx context.Context, ch chan Execution, contracts []Contract) error {
+	ws.removeExecution(ch, contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeExecution(ch chan Execution, contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	if symbols, ok := ws.chExec[ch]; ok {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chExec, ch)
+		} else {
+			ws.chExec[ch] = rest
+		}
+	}
+}
+
+//UnsubMargin - removes margin channel
+func (ws *WS) UnsubMargin(ch chan Margin) (chan struct{}, error) {
+	ws.removeMargin(ch)
+	return ws.unsubUnused()
+}
+
+//UnsubMarginContext - UnsubMargin waiting for confirmation
+func (ws *WS) UnsubMarginContext(ctx context.Context, ch chan Margin) error {
+	ws.removeMargin(ch)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeMargin(ch chan Margin) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	delete(ws.chMargin, ch)
+}
+
+//UnsubWallet - removes wallet channel
+func (ws *WS) UnsubWallet(ch chan Wallet) (chan struct{}, error) {
+	ws.removeWallet(ch)
+	return ws.unsubUnused()
+}
+
+//UnsubWalletContext - UnsubWallet waiting for confirmation
+func (ws *WS) UnsubWalletContext(ctx context.Context, ch chan Wallet) error {
+	ws.removeWallet(ch)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeWallet(ch chan Wallet) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	delete(ws.chWallet, ch)
+}
+
+//UnsubOrderBook - stops maintaining order books of contracts and removes
+//them from every order book channel
+func (ws *WS) UnsubOrderBook(contracts []Contract) (chan struct{}, error) {
+	ws.removeOrderBook(contracts)
+	return ws.unsubUnused()
+}
+
+//UnsubOrderBookContext - UnsubOrderBook waiting for confirmation
+func (ws *WS) UnsubOrderBookContext(ctx context.Context, contracts []Contract) error {
+	ws.removeOrderBook(contracts)
+	return ws.unsubUnusedContext(ctx)
+}
+
+func (ws *WS) removeOrderBook(contracts []Contract) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for _, one := range contracts {
+		delete(ws.books, one)
+	}
+	for ch, symbols := range ws.chBook {
+		if rest, drop := removeContracts(symbols, contracts); drop {
+			delete(ws.chBook, ch)
+		} else {
+			ws.chBook[ch] = rest
+		}
+	}
+}
+
+// neededTopics - topics some listener still depends on, must be called locked
+func (ws *WS) neededTopics() map[string]bool {
+	needed := make(map[string]bool)
+
+	for _, symbols := range ws.chTrade {
+		for _, one := range symbols {
+			needed["trade:"+string(one)] = true
+		}
+	}
+
+	for _, symbols := range ws.chQuote {
+		for _, one := range symbols {
+			needed["quote:"+string(one)] = true
+		}
+	}
+
+	if len(ws.chOrder) > 0 {
+		needed["order"] = true
+	}
+
+	if len(ws.chPosition) > 0 {
+		needed["position"] = true
+	}
+
+	if len(ws.chExec) > 0 {
+		needed["execution"] = true
+	}
+
+	if len(ws.chMargin) > 0 {
+		needed["margin"] = true
+	}
+
+	if len(ws.chWallet) > 0 {
+		needed["wallet"] = true
+	}
+
+	if ws.instruments != nil {
+		needed["instrument"] = true
+	}
+
+	ws.neededStreams(needed)
+
+	for one := range ws.books {
+		needed[OrderBookL2+":"+string(one)] = true
+		needed[OrderBookL225+":"+string(one)] = true
+	}
+
+	return needed
+}
+
+// unsubKey - waiter key of unsubscription, topic alone waits for subscription
+func unsubKey(topic string) string {
+	return "unsubscribe:" + topic
+}
+
+// dropUnused - forgets topics nobody needs anymore and returns them, must be
+// called locked
+func (ws *WS) dropUnused() []string {
+	needed := ws.neededTopics()
+
+	var topics []string
+	for topic := range ws.topics {
+		if !needed[topic] {
+			delete(ws.topics, topic)
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// sendUnsubscribe - sends unsubscription, topic is sent again by next
+// subscription
+func (ws *WS) sendUnsubscribe(topic string) error {
+	err := ws.send(`{"op": "unsubscribe", "args": "` + topic + `"}`)
+	if err == nil {
+		ws.Lock()
+		delete(ws.subscribed, topic)
+		ws.Unlock()
+	}
+	return err
+}
+
+// unsubUnused - unsubscribes from every topic nobody needs anymore. When
+// not connected only local state changes and returned channel is closed.
+// Topics which could not be sent stay subscribed. Returned channel is also
+// closed when server rejects unsubscription, error goes to Errors, and when
+// connection is replaced or closed, which leaves no subscriptions
+func (ws *WS) unsubUnused() (chan struct{}, error) {
+	ws.Lock()
+	topics := ws.dropUnused()
+
+	// waiters are registered before sending, confirmation can't be missed
+	waiters := make([]chan struct{}, len(topics))
+	failures := make([]chan error, len(topics))
+	for i, topic := range topics {
+		waiters[i] = make(chan struct{}, 1)
+		failures[i] = make(chan error, 1)
+		ws.chSucc[unsubKey(topic)] = append(ws.chSucc[unsubKey(topic)], waiters[i])
+		ws.chFail[unsubKey(topic)] = append(ws.chFail[unsubKey(topic)], failures[i])
+	}
+	ws.Unlock()
+
+	done := make(chan struct{})
+
+	for i, topic := range topics {
+		err := ws.sendUnsubscribe(topic)
+		if err == nil {
+			continue
+		}
+
+		for j := i; j < len(topics); j++ {
+			ws.removeWaiter(unsubKey(topics[j]), waiters[j], failures[j])
+		}
+
+		if err == ErrNotConnected {
+			// Nothing to replay after connecting
+			for j := 0; j < i; j++ {
+				ws.removeWaiter(unsubKey(topics[j]), waiters[j], failures[j])
+			}
+			close(done)
+			return done, nil
+		}
+
+		ws.Lock()
+		for j := i; j < len(topics); j++ {
+			ws.topics[topics[j]] = struct{}{}
+		}
+		ws.Unlock()
+		return nil, err
+	}
+
+	go func() {
+		defer close(done)
+
+		for i, topic := range topics {
+			select {
+			case <-waiters[i]:
+			case err := <-failures[i]:
+				ws.error(err)
+			case <-ws.quit:
+			}
+			ws.removeWaiter(unsubKey(topic), waiters[i], failures[i])
+		}
+	}()
+
+	return done, nil
+}
+
+// unsubUnusedContext - unsubUnused waiting for each unsubscription, returns
+// error server rejects it with
+func (ws *WS) unsubUnusedContext(ctx context.Context) error {
+	ws.Lock()
+	topics := ws.dropUnused()
+	ws.Unlock()
+
+	for i, topic := range topics {
+		var sendErr error
+		err := ws.await(ctx, unsubKey(topic), func() error {
+			sendErr = ws.sendUnsubscribe(topic)
+			return sendErr
+		})
+
+		if sendErr == ErrNotConnected {
+			// Nothing to replay after connecting
+			return nil
+		}
+		if sendErr != nil {
+			ws.Lock()
+			for _, one := range topics[i:] {
+				ws.topics[one] = struct{}{}
+			}
+			ws.Unlock()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unsubscribed - server confirmed unsubscription of topic
+func (ws *WS) unsubscribed(topic string) {
+	ws.Lock()
+	defer ws.Unlock()
+
+	for _, ch := range ws.chSucc[unsubKey(topic)] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// unsubscribedAll - connection was replaced or closed, nothing stays
+// subscribed, so pending unsubscriptions are complete. Must be called locked
+func (ws *WS) unsubscribedAll() {
+	for key, channels := range ws.chSucc {
+		if !strings.HasPrefix(key, unsubKey("")) {
+			continue
+		}
+		for _, ch := range channels {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
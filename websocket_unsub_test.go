@@ -0,0 +1,236 @@
+package bitmex_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/santacruz123/bitmex-go"
+	"github.com/santacruz123/bitmex-go/bitmextest"
+)
+
+// failingConn - fails writes while fail is set
+type failingConn struct {
+	bitmex.Conn
+	fail *int32
+}
+
+func (c failingConn) WriteMessage(msg []byte) error {
+	if atomic.LoadInt32(c.fail) != 0 {
+		return errors.New("broken pipe")
+	}
+	return c.Conn.WriteMessage(msg)
+}
+
+var _ = Describe("Unsubscribe", func() {
+	var srv *bitmextest.Server
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	contracts := []bitmex.Contract{bitmex.XBTUSD}
+
+	BeforeEach(func() {
+		srv = bitmextest.NewServer("key", "secret")
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	})
+
+	AfterEach(func() {
+		cancel()
+		srv.Close()
+	})
+
+	It("Should close channel when server confirms", func() {
+		ws := bitmex.NewWS(bitmex.WithDialer(srv.Dialer()))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		trades := make(chan bitmex.WSTrade, 1)
+		Expect(ws.SubTradeContext(ctx, trades, contracts)).To(Succeed())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeTrue())
+
+		done, err := ws.UnsubTrade(trades, nil)
+		Expect(err).To(Succeed())
+		Expect(bitmex.WaitContext(ctx, done)).To(Succeed())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeFalse())
+	})
+
+	It("Should keep topic other channel listens to", func() {
+		ws := bitmex.NewWS(bitmex.WithDialer(srv.Dialer()))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		first := make(chan bitmex.WSTrade, 1)
+		second := make(chan bitmex.WSTrade, 1)
+		Expect(ws.SubTradeContext(ctx, first, contracts)).To(Succeed())
+		Expect(ws.SubTradeContext(ctx, second, contracts)).To(Succeed())
+
+		done, err := ws.UnsubTrade(first, nil)
+		Expect(err).To(Succeed())
+		Expect(bitmex.WaitContext(ctx, done)).To(Succeed())
+		Consistently(func() bool { return srv.Subscribed("trade:XBTUSD") }, 100*time.Millisecond).Should(BeTrue())
+
+		srv.Insert("trade:XBTUSD", []bitmex.WSTrade{{Symbol: "XBTUSD", Price: 6500}})
+		Eventually(second).Should(Receive())
+		Expect(first).NotTo(Receive())
+	})
+
+	It("Should keep topic subscribed when send fails", func() {
+		var fail int32
+		dialer := srv.Dialer()
+		ws := bitmex.NewWS(bitmex.WithDialer(bitmex.DialerFunc(func(ctx context.Context, url string) (bitmex.Conn, error) {
+			conn, err := dialer.Dial(ctx, url)
+			return failingConn{conn, &fail}, err
+		})))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		trades := make(chan bitmex.WSTrade, 1)
+		Expect(ws.SubTradeContext(ctx, trades, contracts)).To(Succeed())
+
+		atomic.StoreInt32(&fail, 1)
+		done, err := ws.UnsubTrade(trades, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(done).To(BeNil())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeTrue())
+
+		// Topic is still known, so next attempt unsubscribes it
+		atomic.StoreInt32(&fail, 0)
+		done, err = ws.UnsubTrade(trades, nil)
+		Expect(err).To(Succeed())
+		Expect(bitmex.WaitContext(ctx, done)).To(Succeed())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeFalse())
+	})
+
+	It("Should only drop listeners when not connected", func() {
+		ws := bitmex.NewWS(bitmex.WithDialer(srv.Dialer()))
+
+		trades := make(chan bitmex.WSTrade, 1)
+		Expect(ws.SubTrade(trades, contracts)).To(Succeed())
+
+		done, err := ws.UnsubTrade(trades, nil)
+		Expect(err).To(Succeed())
+		Expect(done).To(BeClosed())
+
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		Consistently(func() bool { return srv.Subscribed("trade:XBTUSD") }, 100*time.Millisecond).Should(BeFalse())
+	})
+
+	It("Should wait for unsubscription with context", func() {
+		ws := bitmex.NewWS(bitmex.WithDialer(srv.Dialer()))
+		Expect(ws.Connect()).To(Succeed())
+		defer ws.Disconnect()
+
+		trades := make(chan bitmex.WSTrade, 1)
+		Expect(ws.SubTradeContext(ctx, trades, contracts)).To(Succeed())
+
+		Expect(ws.UnsubTradeContext(ctx, trades, nil)).To(Succeed())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeFalse())
+
+		// Subscribed again after unsubscription
+		Expect(ws.SubTradeContext(ctx, trades, contracts)).To(Succeed())
+		Expect(srv.Subscribed("trade:XBTUSD")).To(BeTrue())
+	})
+
+	Context("Pending", func() {
+		var tr *bitmextest.Transport
+		var ws *bitmex.WS
+		var server *bitmextest.MemConn
+		var trades chan bitmex.WSTrade
+		var quotes chan bitmex.WSQuote
+		var connected bool
+
+		// read - next request of client, ping is skipped
+		read := func() string {
+			for {
+				msg, err := server.ReadMessage()
+				Expect(err).To(Succeed())
+				if string(msg) != "ping" {
+					return string(msg)
+				}
+			}
+		}
+
+		BeforeEach(func() {
+			tr = bitmextest.NewTransport()
+			ws = bitmex.NewWS(
+				bitmex.WithDialer(tr),
+				bitmex.WithHeartbeat(0, 0),
+				bitmex.WithReconnect(time.Millisecond, time.Millisecond),
+			)
+			Expect(ws.Connect()).To(Succeed())
+			connected = true
+
+			var err error
+			server, err = tr.Accept(ctx)
+			Expect(err).To(Succeed())
+
+			trades = make(chan bitmex.WSTrade)
+			quotes = make(chan bitmex.WSQuote)
+			Expect(ws.SubTrade(trades, contracts)).To(Succeed())
+			Expect(ws.SubQuote(quotes, contracts)).To(Succeed())
+			Expect(read()).To(ContainSubstring(`"subscribe"`))
+			Expect(read()).To(ContainSubstring(`"subscribe"`))
+		})
+
+		AfterEach(func() {
+			if connected {
+				ws.Disconnect()
+			}
+		})
+
+		It("Should return rejected unsubscription", func() {
+			result := make(chan error, 1)
+			go func() {
+				result <- ws.UnsubTradeContext(ctx, trades, nil)
+			}()
+			Expect(read()).To(ContainSubstring(`"unsubscribe"`))
+
+			server.WriteMessage([]byte(`{"status":400,"error":"You weren't subscribed to trade:XBTUSD.","meta":{},"request":{"op":"unsubscribe","args":"trade:XBTUSD"}}`))
+			Eventually(result).Should(Receive(MatchError(ContainSubstring("weren't subscribed"))))
+			Expect(ws.Errors()).NotTo(Receive())
+		})
+
+		It("Should complete pending unsubscriptions on reconnect", func() {
+			done, err := ws.UnsubTrade(trades, nil)
+			Expect(err).To(Succeed())
+
+			result := make(chan error, 1)
+			go func() {
+				result <- ws.UnsubQuoteContext(ctx, quotes, nil)
+			}()
+			Expect(read()).To(ContainSubstring(`"unsubscribe"`))
+			Expect(read()).To(ContainSubstring(`"unsubscribe"`))
+			Consistently(done, 50*time.Millisecond).ShouldNot(BeClosed())
+
+			// New connection starts without the topics
+			server.Close()
+			_, err = tr.Accept(ctx)
+			Expect(err).To(Succeed())
+
+			Eventually(done).Should(BeClosed())
+			Eventually(result).Should(Receive(BeNil()))
+		})
+
+		It("Should complete pending unsubscriptions on disconnect", func() {
+			done, err := ws.UnsubTrade(trades, nil)
+			Expect(err).To(Succeed())
+
+			result := make(chan error, 1)
+			go func() {
+				result <- ws.UnsubQuoteContext(ctx, quotes, nil)
+			}()
+			Expect(read()).To(ContainSubstring(`"unsubscribe"`))
+			Expect(read()).To(ContainSubstring(`"unsubscribe"`))
+
+			ws.Disconnect()
+			connected = false
+			Eventually(done).Should(BeClosed())
+			Eventually(result).Should(Receive(BeNil()))
+		})
+	})
+})